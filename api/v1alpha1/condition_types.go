@@ -0,0 +1,176 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Condition contains details for one aspect of the current state of an
+// updater.
+type Condition struct {
+	// Type of the condition.
+	// +required
+	Type string `json:"type"`
+
+	// Status of the condition, one of True, False, Unknown.
+	// +required
+	Status corev1.ConditionStatus `json:"status"`
+
+	// LastTransitionTime is the last time the condition changed from one
+	// status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// Reason is a CamelCase reason for the last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message is a human readable description of the last transition.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	// ReadyCondition records whether or not the updater was able to apply
+	// the latest image to the Application.
+	ReadyCondition string = "Ready"
+
+	// DowngradeBlockedCondition records whether or not the latest image was
+	// not applied because it is older than the Application's current image.
+	DowngradeBlockedCondition string = "DowngradeBlocked"
+
+	// VerificationFailedCondition records whether or not the latest image was
+	// not applied because its signature could not be verified.
+	VerificationFailedCondition string = "VerificationFailed"
+
+	// ConflictCondition records whether or not another updater for the same
+	// Application writes an image with the same name.
+	ConflictCondition string = "Conflict"
+)
+
+const (
+	// ReconciliationSucceededReason means the Application was updated, or
+	// already had the latest image.
+	ReconciliationSucceededReason string = "ReconciliationSucceeded"
+
+	// ClusterConnectionFailedReason means the controller could not connect
+	// to the cluster that the Application lives in.
+	ClusterConnectionFailedReason string = "ClusterConnectionFailed"
+
+	// KubeConfigErrorReason means the kubeconfig for a remote cluster could
+	// not be loaded.
+	KubeConfigErrorReason string = "KubeConfigError"
+
+	// ArgoCDAPIErrorReason means the ArgoCD API server could not be used to
+	// load or update the Application.
+	ArgoCDAPIErrorReason string = "ArgoCDAPIError"
+
+	// InvalidSpecReason means the updater's spec can't be used.
+	InvalidSpecReason string = "InvalidSpec"
+
+	// TemplateErrorReason means the commonLabels or commonAnnotations
+	// templates could not be rendered with the image.
+	TemplateErrorReason string = "TemplateError"
+
+	// DowngradeBlockedReason means the latest image is older than the
+	// Application's current image, and downgrade protection is enabled.
+	DowngradeBlockedReason string = "DowngradeBlocked"
+
+	// FilteredReason means the latest image was rejected by the tag filter,
+	// and the Application keeps its current image.
+	FilteredReason string = "Filtered"
+
+	// VerificationFailedReason means the latest image has no signature that
+	// can be verified with the public keys.
+	VerificationFailedReason string = "VerificationFailed"
+
+	// PublicKeysErrorReason means the public keys for verification could not
+	// be loaded.
+	PublicKeysErrorReason string = "PublicKeysError"
+
+	// RegistryErrorReason means the image registry could not be accessed.
+	RegistryErrorReason string = "RegistryError"
+
+	// ImageNotFoundReason means the latest image doesn't exist in the
+	// registry that the cluster pulls from, the update is deferred.
+	ImageNotFoundReason string = "ImageNotFound"
+
+	// UpdateDeferredReason means the latest image is waiting for the
+	// minInterval or stabilizationWindow to pass.
+	UpdateDeferredReason string = "UpdateDeferred"
+
+	// ConflictReason means another updater for the same Application writes
+	// an image with the same name, and has the same or a higher priority.
+	ConflictReason string = "Conflict"
+
+	// PrecedenceReason means the updater has a higher priority than the
+	// other updaters that write an image with the same name.
+	PrecedenceReason string = "Precedence"
+
+	// SuspendedReason means the updater is suspended.
+	SuspendedReason string = "Suspended"
+
+	// TargetNotFoundReason means the Application or ApplicationSet doesn't
+	// exist.
+	TargetNotFoundReason string = "TargetNotFound"
+
+	// ImagePolicyNotFoundReason means the ImagePolicy doesn't exist.
+	ImagePolicyNotFoundReason string = "ImagePolicyNotFound"
+
+	// NoLatestImageReason means the ImagePolicy hasn't selected an image
+	// yet, the Application is not changed until it does.
+	NoLatestImageReason string = "NoLatestImage"
+
+	// PermissionDeniedReason means the controller, or the impersonated
+	// ServiceAccount, is not allowed to access a resource.
+	PermissionDeniedReason string = "PermissionDenied"
+
+	// ReconciliationFailedReason means the reconciliation failed for a
+	// reason that is not more specific.
+	ReconciliationFailedReason string = "ReconciliationFailed"
+)
+
+// FindCondition returns the condition with the given type, or nil if there is
+// none.
+func FindCondition(conditions []Condition, conditionType string) *Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or replaces the condition with the same type.
+//
+// The LastTransitionTime is only changed when the status changes.
+func SetCondition(conditions []Condition, c Condition) []Condition {
+	existing := FindCondition(conditions, c.Type)
+	if existing == nil {
+		if c.LastTransitionTime.IsZero() {
+			c.LastTransitionTime = metav1.Now()
+		}
+		return append(conditions, c)
+	}
+	if existing.Status != c.Status {
+		existing.Status = c.Status
+		existing.LastTransitionTime = metav1.Now()
+	}
+	existing.Reason = c.Reason
+	existing.Message = c.Message
+	return conditions
+}
@@ -15,22 +15,641 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"time"
+
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // ImagePolicyArgoCDUpdateSpec defines the desired state of ImagePolicyArgoCDUpdate
 type ImagePolicyArgoCDUpdateSpec struct {
-	ApplicationRef corev1.ObjectReference      `json:"applicationRef"`
+	// ApplicationRef is the Application to update.
+	//
+	// Exactly one of ApplicationRef and ApplicationSetRef must be provided.
+	// +optional
+	ApplicationRef *ApplicationReference `json:"applicationRef,omitempty"`
+
+	// ApplicationSetRef is an ApplicationSet whose template is updated, so
+	// that all the Applications it generates get the new image.
+	// +optional
+	ApplicationSetRef *ApplicationSetReference `json:"applicationSetRef,omitempty"`
+
 	ImagePolicyRef corev1.LocalObjectReference `json:"imagePolicyRef"`
+
+	// Source selects the source to update in an Application, or
+	// ApplicationSet template, with several sources in spec.sources.
+	//
+	// This is required if there is more than one source.
+	// +optional
+	Source *SourceSelector `json:"source,omitempty"`
+
+	// Strategy configures how the image is written to the source.
+	//
+	// If this is not provided, the image is added to the Kustomize images.
+	// +optional
+	Strategy *UpdateStrategy `json:"strategy,omitempty"`
+
+	// ServiceAccountName is the name of a ServiceAccount in the same namespace
+	// as the updater, the controller impersonates it when reading the
+	// ImagePolicy and updating the Application.
+	//
+	// If this is not provided, the controller uses its own identity.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Provenance configures how changes are recorded on the Application, in
+	// addition to the annotations that are always written.
+	// +optional
+	Provenance *ProvenanceSpec `json:"provenance,omitempty"`
+
+	// DowngradeProtection refuses to apply an image that is older than the
+	// image that the Application currently has.
+	// +optional
+	DowngradeProtection *DowngradeProtection `json:"downgradeProtection,omitempty"`
+
+	// TagFilter restricts the images from the ImagePolicy that are applied,
+	// if the latest image is rejected, the Application keeps its current
+	// image.
+	// +optional
+	TagFilter *TagFilter `json:"tagFilter,omitempty"`
+
+	// Verification requires a valid cosign signature for the latest image
+	// before it is applied.
+	// +optional
+	Verification *VerificationSpec `json:"verification,omitempty"`
+
+	// ImageCheck confirms that the latest image exists in the registry that
+	// the cluster pulls from before it is applied.
+	// +optional
+	ImageCheck *ImageCheckSpec `json:"imageCheck,omitempty"`
+
+	// MinInterval is the minimum time between two updates of the
+	// Application, by this or any other updater, a newer image is applied
+	// when the interval has passed.
+	// +optional
+	MinInterval *metav1.Duration `json:"minInterval,omitempty"`
+
+	// StabilizationWindow is how long the latest image must remain unchanged
+	// before it is applied.
+	// +optional
+	StabilizationWindow *metav1.Duration `json:"stabilizationWindow,omitempty"`
+
+	// Priority decides which updater applies its image when several updaters
+	// for the same Application have images with the same name, the updater
+	// with the highest priority wins.
+	//
+	// If the highest priorities are equal, none of the conflicting updaters
+	// are applied until the conflict is resolved.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+
+	// Suspend stops the updater from applying images, the Application keeps
+	// its current image.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// PinnedImage is applied instead of the ImagePolicy's latest image, until
+	// it is cleared.
+	//
+	// The tag filter, downgrade protection and timing windows don't apply to
+	// a pinned image.
+	// +optional
+	PinnedImage string `json:"pinnedImage,omitempty"`
+
+	// Retry configures how failed reconciliations are retried.
+	// +optional
+	Retry *RetryPolicy `json:"retry,omitempty"`
+}
+
+// Defaults for the RetryPolicy.
+const (
+	DefaultRetryInterval   = 10 * time.Second
+	DefaultRetryMaxBackoff = 5 * time.Minute
+)
+
+// RetryPolicy configures how failed reconciliations are retried.
+//
+// Transient errors, for example failing to connect to a cluster or registry,
+// are retried after the Interval, which doubles with each consecutive
+// failure, up to the MaxBackoff.
+//
+// Configuration errors, for example a missing Application, and permission
+// errors, are retried after the MaxBackoff, changing the spec triggers a
+// retry immediately.
+type RetryPolicy struct {
+	// Interval is the delay before the first retry, it defaults to 10s, which
+	// is also used if it's not positive.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// MaxBackoff is the longest delay between retries, it defaults to 5m,
+	// which is also used if it's not positive.
+	// +optional
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
+}
+
+// GetInterval returns the Interval, or the default if it's not set or not
+// positive.
+func (p *RetryPolicy) GetInterval() time.Duration {
+	if p == nil || p.Interval == nil || p.Interval.Duration <= 0 {
+		return DefaultRetryInterval
+	}
+	return p.Interval.Duration
+}
+
+// GetMaxBackoff returns the MaxBackoff, or the default if it's not set or not
+// positive.
+func (p *RetryPolicy) GetMaxBackoff() time.Duration {
+	if p == nil || p.MaxBackoff == nil || p.MaxBackoff.Duration <= 0 {
+		return DefaultRetryMaxBackoff
+	}
+	return p.MaxBackoff.Duration
+}
+
+// ErrorClass classifies reconciliation errors by how they are retried.
+// +kubebuilder:validation:Enum=Transient;Configuration;Permission
+type ErrorClass string
+
+const (
+	// TransientError is an error that is likely to be fixed by retrying.
+	TransientError ErrorClass = "Transient"
+	// ConfigurationError is an error in the updater's spec, or the resources
+	// that it references.
+	ConfigurationError ErrorClass = "Configuration"
+	// PermissionError means that access to a resource was denied.
+	PermissionError ErrorClass = "Permission"
+)
+
+// RetryStatus describes the failures since the last successful
+// reconciliation.
+type RetryStatus struct {
+	// Class is the class of the last error.
+	Class ErrorClass `json:"class"`
+
+	// Failures is the number of consecutive failed reconciliations.
+	Failures int32 `json:"failures"`
+
+	// NextRetryTime is when the reconciliation will be retried.
+	NextRetryTime metav1.Time `json:"nextRetryTime"`
+}
+
+// ReconcileRequestedAnnotation can be set on an updater to trigger a
+// reconciliation, the value is usually the time of the request.
+const ReconcileRequestedAnnotation = "apps.bigkevmcd.com/reconcile-requested-at"
+
+// ImageCheckSpec configures the check for the latest image in the registry.
+type ImageCheckSpec struct {
+	// PullSecrets are "kubernetes.io/dockerconfigjson" Secrets in the same
+	// namespace as the updater, with credentials for the registry.
+	// +optional
+	PullSecrets []corev1.LocalObjectReference `json:"pullSecrets,omitempty"`
+
+	// Mirror is the host of the registry that the cluster pulls images from
+	// e.g. "mirror.example.com:5000", if it's not the image's own registry.
+	// +optional
+	Mirror string `json:"mirror,omitempty"`
+
+	// RetryInterval is how long to wait before checking again for an image
+	// that doesn't exist, it defaults to one minute.
+	// +optional
+	RetryInterval *metav1.Duration `json:"retryInterval,omitempty"`
+}
+
+// DefaultImageCheckRetryInterval is used if no RetryInterval is provided.
+const DefaultImageCheckRetryInterval = time.Minute
+
+// GetRetryInterval returns the RetryInterval, or the default.
+func (s ImageCheckSpec) GetRetryInterval() time.Duration {
+	if s.RetryInterval == nil {
+		return DefaultImageCheckRetryInterval
+	}
+	return s.RetryInterval.Duration
+}
+
+// VerificationSpec configures the verification of image signatures.
+type VerificationSpec struct {
+	// PublicKeys are the keys that the image's signature is verified with.
+	PublicKeys PublicKeysReference `json:"publicKeys"`
+}
+
+// PublicKeysReference references a Secret or ConfigMap in the same namespace
+// as the updater, every value is one or more PEM encoded ECDSA public keys.
+//
+// Exactly one of SecretRef and ConfigMapRef must be provided.
+type PublicKeysReference struct {
+	// +optional
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+
+	// +optional
+	ConfigMapRef *corev1.LocalObjectReference `json:"configMapRef,omitempty"`
+}
+
+// TagFilter restricts the tags of the images that are applied, a tag must
+// pass all the configured checks.
+type TagFilter struct {
+	// Include is a regular expression that the tag must match.
+	// +optional
+	Include string `json:"include,omitempty"`
+
+	// Exclude is a regular expression that the tag must not match e.g.
+	// "-rc".
+	// +optional
+	Exclude string `json:"exclude,omitempty"`
+
+	// SemVer is a semantic version constraint that the tag must satisfy
+	// e.g. ">= 1.2.0, < 2.0.0".
+	// +optional
+	SemVer string `json:"semver,omitempty"`
+}
+
+// DowngradeProtection configures how the current and latest images are
+// compared.
+type DowngradeProtection struct {
+	// Ordering is used to compare the tags of the current and latest images.
+	//
+	// If this is not provided, it matches the ImagePolicy's semver,
+	// numerical or alphabetical policy, including its order, and is
+	// Alphabetical otherwise.
+	// +optional
+	Ordering TagOrdering `json:"ordering,omitempty"`
+
+	// AllowedImage is applied even if it is older than the current image,
+	// so that a downgrade can be made deliberately.
+	// +optional
+	AllowedImage string `json:"allowedImage,omitempty"`
+}
+
+// TagOrdering is a way of ordering image tags.
+// +kubebuilder:validation:Enum=SemVer;Numerical;Alphabetical
+type TagOrdering string
+
+const (
+	// SemVerOrdering orders tags as semantic versions e.g. "v1.2.3".
+	SemVerOrdering TagOrdering = "SemVer"
+	// NumericalOrdering orders tags as numbers e.g. "20200819120130".
+	NumericalOrdering TagOrdering = "Numerical"
+	// AlphabeticalOrdering orders tags lexically e.g.
+	// "RELEASE.2020-08-19T12-01-30Z".
+	AlphabeticalOrdering TagOrdering = "Alphabetical"
+)
+
+// ProvenanceSpec configures the spec.info entries that record changes on the
+// Application, these are shown in the ArgoCD UI.
+type ProvenanceSpec struct {
+	// Info maintains entries in the Application's spec.info, recording the
+	// updater and the image it applied.
+	// +optional
+	Info bool `json:"info,omitempty"`
+
+	// URL is added to spec.info as a link, e.g. to the image's changelog.
+	//
+	// The value is a Go template, with the fields .Image, .Name, .Tag and
+	// .Digest from the new image.
+	// +optional
+	URL string `json:"url,omitempty"`
+}
+
+// These annotations are written to the Application, or ApplicationSet, when
+// the image is changed.
+const (
+	// UpdatedByAnnotation is the namespace/name of the updater.
+	UpdatedByAnnotation = "apps.bigkevmcd.com/updated-by"
+	// PreviousImageAnnotation is the image that was replaced, it is empty if
+	// there was no image.
+	PreviousImageAnnotation = "apps.bigkevmcd.com/previous-image"
+	// ImageAnnotation is the image that was applied.
+	ImageAnnotation = "apps.bigkevmcd.com/image"
+	// ImagePolicyGenerationAnnotation is the generation of the ImagePolicy
+	// that selected the image.
+	ImagePolicyGenerationAnnotation = "apps.bigkevmcd.com/image-policy-generation"
+	// UpdatedAtAnnotation is the time of the change, in RFC 3339 format.
+	UpdatedAtAnnotation = "apps.bigkevmcd.com/updated-at"
+)
+
+// ApplicationReference identifies the ArgoCD Application to update.
+type ApplicationReference struct {
+	// Name is the name of the Application.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the Application.
+	//
+	// With the API, this is sent as the Application's namespace, it can be
+	// left empty for Applications in ArgoCD's own namespace.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// KubeConfig references a Secret with a kubeconfig for the cluster that
+	// the Application lives in.
+	//
+	// If this is not provided, the Application is in the same cluster as the
+	// controller.
+	// +optional
+	KubeConfig *KubeConfigReference `json:"kubeConfig,omitempty"`
+
+	// API configures access to the Application through the ArgoCD API server,
+	// rather than the Kubernetes API, so that ArgoCD's own RBAC and audit
+	// logging apply.
+	//
+	// This can't be combined with KubeConfig.
+	// +optional
+	API *ArgoCDAPIReference `json:"api,omitempty"`
+}
+
+// ArgoCDAPIReference configures access to the ArgoCD API server.
+type ArgoCDAPIReference struct {
+	// SecretRef is a Secret in the same namespace as the updater, with the
+	// URL of the API server in the "server" key, and an ArgoCD API token in
+	// the "token" key.
+	SecretRef corev1.LocalObjectReference `json:"secretRef"`
+
+	// Sync triggers a sync of the Application after it has been updated.
+	// +optional
+	Sync bool `json:"sync,omitempty"`
+
+	// Insecure disables verification of the API server's TLS certificate.
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// ApplicationSetReference identifies the ArgoCD ApplicationSet to update.
+type ApplicationSetReference struct {
+	// Name is the name of the ApplicationSet.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the ApplicationSet.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// SourceSelector selects one of the sources of a multi-source Application.
+//
+// At most one of the fields can be provided, and it must match exactly one
+// source.
+type SourceSelector struct {
+	// Index is the position of the source in spec.sources, starting at 0.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Index *int32 `json:"index,omitempty"`
+
+	// RepoURL selects the source with this repository URL.
+	// +optional
+	RepoURL string `json:"repoURL,omitempty"`
+
+	// Name selects the source with this name.
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// UpdateStrategy configures how the image is written to an ArgoCD
+// ApplicationSource.
+type UpdateStrategy struct {
+	// Kustomize adds the image to the Kustomize image overrides, replacing
+	// any image with the same name.
+	// +optional
+	Kustomize *KustomizeStrategy `json:"kustomize,omitempty"`
+
+	// Helm sets Helm parameters to the image, or its components.
+	// +optional
+	Helm *HelmStrategy `json:"helm,omitempty"`
+
+	// Jsonnet sets Jsonnet external variables or top-level arguments of a
+	// directory source to the image, or its components.
+	// +optional
+	Jsonnet *JsonnetStrategy `json:"jsonnet,omitempty"`
+
+	// Plugin sets environment variables of a config management plugin source
+	// to the image, or its components.
+	// +optional
+	Plugin *PluginStrategy `json:"plugin,omitempty"`
+
+	// TargetRevision sets the source's targetRevision to the image tag, or
+	// another component, for sources that are versioned by the policy e.g.
+	// Helm charts in an OCI repository, or Git tags.
+	// +optional
+	TargetRevision *TargetRevisionStrategy `json:"targetRevision,omitempty"`
+}
+
+// KustomizeStrategy configures the Kustomize image override.
+type KustomizeStrategy struct {
+	// CommonLabels are added to the Kustomize commonLabels along with the
+	// image.
+	//
+	// The values are Go templates, with the fields .Image, .Name, .Tag and
+	// .Digest from the new image e.g. "{{ .Tag }}".
+	//
+	// The labels are removed when the updater is deleted.
+	// +optional
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+
+	// CommonAnnotations are added to the Kustomize commonAnnotations along with
+	// the image, the values are templated in the same way as CommonLabels.
+	//
+	// The annotations are removed when the updater is deleted.
+	// +optional
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+}
+
+// CommonMetadataKeys returns the keys of the labels and annotations that the
+// strategy adds.
+func (s *KustomizeStrategy) CommonMetadataKeys() (labels, annotations []string) {
+	if s == nil {
+		return nil, nil
+	}
+	for k := range s.CommonLabels {
+		labels = append(labels, k)
+	}
+	for k := range s.CommonAnnotations {
+		annotations = append(annotations, k)
+	}
+	return labels, annotations
+}
+
+// HelmStrategy names the Helm parameters, and the fields in the inline Helm
+// values, that are set from the image.
+//
+// At least one parameter or value must be provided.
+type HelmStrategy struct {
+	// ImageParameter is set to the full image e.g.
+	// "docker.io/bigkevmcd/go-demo:af93dae".
+	// +optional
+	ImageParameter string `json:"imageParameter,omitempty"`
+
+	// RepositoryParameter is set to the image name without the tag e.g.
+	// "docker.io/bigkevmcd/go-demo".
+	// +optional
+	RepositoryParameter string `json:"repositoryParameter,omitempty"`
+
+	// TagParameter is set to the image tag e.g. "af93dae", or the digest if
+	// the image has no tag.
+	// +optional
+	TagParameter string `json:"tagParameter,omitempty"`
+
+	// Values are fields in the inline Helm values, in helm.values, that are
+	// set to the image, or its components.
+	//
+	// This can set fields that parameters can't express, e.g. entries in
+	// lists, and keeps the comments and order of the values.
+	// +optional
+	Values []HelmValue `json:"values,omitempty"`
+}
+
+// HelmValue is a field in the inline Helm values.
+type HelmValue struct {
+	// Path is the path to the field, with keys separated by "." and list
+	// entries selected by their index e.g. "services[2].image".
+	//
+	// Missing keys are added, but list entries must already exist.
+	Path string `json:"path"`
+
+	// Component of the image that the field is set to, it defaults to the
+	// full image.
+	// +optional
+	Component ImageComponent `json:"component,omitempty"`
+}
+
+// JsonnetStrategy names the Jsonnet variables that are set from the image.
+type JsonnetStrategy struct {
+	// ExtVars are Jsonnet external variables.
+	// +optional
+	ExtVars []ImageVariable `json:"extVars,omitempty"`
+
+	// TLAs are Jsonnet top-level arguments.
+	// +optional
+	TLAs []ImageVariable `json:"tlas,omitempty"`
+}
+
+// PluginStrategy names the plugin environment variables that are set from the
+// image.
+type PluginStrategy struct {
+	// Env are environment variables passed to the plugin.
+	// +optional
+	Env []ImageVariable `json:"env,omitempty"`
+}
+
+// TargetRevisionStrategy configures the update of the source's
+// targetRevision.
+type TargetRevisionStrategy struct {
+	// Component of the image that the targetRevision is set to, it defaults
+	// to the tag.
+	// +optional
+	Component ImageComponent `json:"component,omitempty"`
+}
+
+// ImageComponent is a part of an image reference.
+// +kubebuilder:validation:Enum=Image;Name;Tag;Digest
+type ImageComponent string
+
+const (
+	// ImageComponentImage is the full image e.g.
+	// "docker.io/bigkevmcd/go-demo:af93dae".
+	ImageComponentImage ImageComponent = "Image"
+	// ImageComponentName is the image without the tag or digest e.g.
+	// "docker.io/bigkevmcd/go-demo".
+	ImageComponentName ImageComponent = "Name"
+	// ImageComponentTag is the image tag e.g. "af93dae".
+	ImageComponentTag ImageComponent = "Tag"
+	// ImageComponentDigest is the image digest e.g. "sha256:...".
+	ImageComponentDigest ImageComponent = "Digest"
+)
+
+// ImageVariable names a variable that is set to the image, or one of its
+// components.
+type ImageVariable struct {
+	// Name of the variable.
+	Name string `json:"name"`
+
+	// Component of the image that the variable is set to, it defaults to the
+	// full image.
+	// +optional
+	Component ImageComponent `json:"component,omitempty"`
+}
+
+// KubeConfigReference references a kubeconfig stored in a Secret.
+type KubeConfigReference struct {
+	// SecretRef is a Secret in the same namespace as the updater.
+	SecretRef corev1.LocalObjectReference `json:"secretRef"`
+
+	// Key is the key in the Secret that holds the kubeconfig, it defaults to
+	// "value".
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// DefaultKubeConfigSecretKey is the key in the Secret that is used if no Key
+// is provided.
+const DefaultKubeConfigSecretKey = "value"
+
+// SecretKey returns the key in the Secret that holds the kubeconfig.
+func (r KubeConfigReference) SecretKey() string {
+	if r.Key == "" {
+		return DefaultKubeConfigSecretKey
+	}
+	return r.Key
 }
 
 // ImagePolicyArgoCDUpdateStatus defines the observed state of ImagePolicyArgoCDUpdate
 type ImagePolicyArgoCDUpdateStatus struct {
+	// ObservedGeneration is the last generation of the updater that was
+	// reconciled.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastUpdateTime is when the updater last changed the image.
+	// +optional
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+
+	// CandidateImage is the latest image, while it is waiting for the
+	// MinInterval or StabilizationWindow to pass.
+	// +optional
+	CandidateImage string `json:"candidateImage,omitempty"`
+
+	// CandidateSince is when the CandidateImage was first seen.
+	// +optional
+	CandidateSince *metav1.Time `json:"candidateSince,omitempty"`
+
+	// LatestImage is the ImagePolicy's latest image when the updater was
+	// last reconciled.
+	// +optional
+	LatestImage string `json:"latestImage,omitempty"`
+
+	// PinnedImage is the pinned image that the updater is applying instead
+	// of the LatestImage.
+	// +optional
+	PinnedImage string `json:"pinnedImage,omitempty"`
+
+	// History is the images that the updater applied, the most recent first,
+	// up to MaxImageHistory entries.
+	// +optional
+	History []ImageHistoryEntry `json:"history,omitempty"`
+
+	// Retry is set while reconciliations are failing.
+	// +optional
+	Retry *RetryStatus `json:"retry,omitempty"`
+
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// MaxImageHistory is the number of applied images kept in an updater's
+// status.
+const MaxImageHistory = 10
+
+// ImageHistoryEntry is an image that an updater applied.
+type ImageHistoryEntry struct {
+	Image     string      `json:"image"`
+	AppliedAt metav1.Time `json:"appliedAt"`
+
+	// Pinned is true if the image was the updater's PinnedImage.
+	// +optional
+	Pinned bool `json:"pinned,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].message"
+// +kubebuilder:printcolumn:name="Latest",type="string",JSONPath=".status.latestImage",priority=1
+// +kubebuilder:printcolumn:name="Pinned",type="string",JSONPath=".status.pinnedImage",priority=1
 
 // ImagePolicyArgoCDUpdate is the Schema for the imagepolicyargocdupdates API
 type ImagePolicyArgoCDUpdate struct {
@@ -50,6 +669,32 @@ type ImagePolicyArgoCDUpdateList struct {
 	Items           []ImagePolicyArgoCDUpdate `json:"items"`
 }
 
+// TargetName identifies the Application or ApplicationSet that the updater
+// writes to as "Kind/namespace/name", it is empty if the updater doesn't
+// reference exactly one of them.
+func TargetName(u *ImagePolicyArgoCDUpdate) string {
+	spec := u.Spec
+	switch {
+	case (spec.ApplicationRef == nil) == (spec.ApplicationSetRef == nil):
+		return ""
+	case spec.ApplicationSetRef != nil:
+		return "ApplicationSet/" + spec.ApplicationSetRef.Namespace + "/" + spec.ApplicationSetRef.Name
+	}
+	return "Application/" + spec.ApplicationRef.Namespace + "/" + spec.ApplicationRef.Name
+}
+
+// SetReadiness sets the Ready condition on the updater, and records the
+// generation that was reconciled.
+func SetReadiness(u *ImagePolicyArgoCDUpdate, status corev1.ConditionStatus, reason, message string) {
+	u.Status.Conditions = SetCondition(u.Status.Conditions, Condition{
+		Type:    ReadyCondition,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+	u.Status.ObservedGeneration = u.Generation
+}
+
 func init() {
 	SchemeBuilder.Register(&ImagePolicyArgoCDUpdate{}, &ImagePolicyArgoCDUpdateList{})
 }
@@ -0,0 +1,196 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// argo-image-policy inspects and drives ImagePolicyArgoCDUpdates.
+//
+// It can be used standalone, or installed as kubectl-argo_image_policy and
+// used as "kubectl argo-image-policy".
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/cli"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/imagepolicy"
+)
+
+const usage = `Usage: %[1]s <command> [flags] [arguments]
+
+Commands:
+  list                  list the updaters, with their current and candidate images
+  diff <name>           show the changes the updater would make to its Application
+  reconcile <name>      request an immediate reconcile of the updater
+  pin <name> <image>    apply the image instead of the ImagePolicy's latest image
+  unpin <name>          go back to applying the ImagePolicy's latest image
+  rollback <name>       pin the image that was applied before the current one
+  suspend <name>        stop the updater from applying images
+  resume <name>         resume a suspended updater
+
+Run "%[1]s <command> -h" for the command's flags.
+`
+
+type options struct {
+	kubeconfig    string
+	context       string
+	namespace     string
+	allNamespaces bool
+	historyEntry  int
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out, errOut io.Writer) error {
+	name := commandName(os.Args[0])
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprintf(errOut, usage, name)
+		return nil
+	}
+	command, args := args[0], args[1:]
+
+	var opts options
+	fs := flag.NewFlagSet(name+" "+command, flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	fs.StringVar(&opts.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use.")
+	fs.StringVar(&opts.context, "context", "", "The name of the kubeconfig context to use.")
+	fs.StringVar(&opts.namespace, "namespace", "", "The namespace of the updaters, defaults to the context's namespace.")
+	fs.StringVar(&opts.namespace, "n", "", "Shorthand for --namespace.")
+	if command == "list" {
+		fs.BoolVar(&opts.allNamespaces, "all-namespaces", false, "List the updaters in all namespaces.")
+		fs.BoolVar(&opts.allNamespaces, "A", false, "Shorthand for --all-namespaces.")
+	}
+	if command == "rollback" {
+		fs.IntVar(&opts.historyEntry, "to", 1, "The history entry to roll back to, 0 is the most recently applied image.")
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	args = fs.Args()
+
+	wantArgs := map[string]int{"list": 0, "diff": 1, "reconcile": 1, "pin": 2, "unpin": 1, "rollback": 1, "suspend": 1, "resume": 1}
+	n, ok := wantArgs[command]
+	if !ok {
+		fmt.Fprintf(errOut, usage, name)
+		return fmt.Errorf("unknown command %q", command)
+	}
+	if len(args) != n {
+		return fmt.Errorf("%s takes %d arguments, got %d", command, n, len(args))
+	}
+
+	c, policies, namespace, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if command == "list" {
+		if opts.allNamespaces {
+			namespace = ""
+		}
+		return cli.List(ctx, c, policies, out, namespace)
+	}
+
+	updater := types.NamespacedName{Name: args[0], Namespace: namespace}
+	switch command {
+	case "diff":
+		return cli.Diff(ctx, c, policies, out, updater)
+	case "reconcile":
+		return report(out, cli.RequestReconcile(ctx, c, updater, time.Now()), "requested a reconcile of %s", updater)
+	case "pin":
+		return report(out, cli.Pin(ctx, c, updater, args[1]), "pinned %s to %s", updater, args[1])
+	case "unpin":
+		return report(out, cli.Pin(ctx, c, updater, ""), "unpinned %s", updater)
+	case "rollback":
+		image, err := cli.Rollback(ctx, c, updater, opts.historyEntry)
+		return report(out, err, "pinned %s to %s", updater, image)
+	case "suspend":
+		return report(out, cli.Suspend(ctx, c, updater, true), "suspended %s", updater)
+	default:
+		return report(out, cli.Suspend(ctx, c, updater, false), "resumed %s", updater)
+	}
+}
+
+func report(out io.Writer, err error, format string, args ...interface{}) error {
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, format+"\n", args...)
+	return nil
+}
+
+// newClient creates a client from the kubeconfig, and returns a reader for the
+// ImagePolicy version that the cluster serves, and the namespace to use.
+func newClient(opts options) (client.Client, imagepolicy.Reader, string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = opts.kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: opts.context}
+	overrides.Context.Namespace = opts.namespace
+	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+
+	var policies imagepolicy.Reader
+	namespace, _, err := config.Namespace()
+	if err != nil {
+		return nil, policies, "", fmt.Errorf("failed to get the namespace: %w", err)
+	}
+	restConfig, err := config.ClientConfig()
+	if err != nil {
+		return nil, policies, "", fmt.Errorf("failed to load the kubeconfig: %w", err)
+	}
+	scheme, err := cli.NewScheme()
+	if err != nil {
+		return nil, policies, "", err
+	}
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, policies, "", fmt.Errorf("failed to create the client: %w", err)
+	}
+	d, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, policies, "", fmt.Errorf("failed to create the discovery client: %w", err)
+	}
+	policies.Version, err = imagepolicy.ServedVersion(d)
+	if err != nil {
+		return nil, policies, "", err
+	}
+	return c, policies, namespace, nil
+}
+
+// commandName returns the name to show in the usage, kubectl plugins are
+// shown as kubectl subcommands.
+func commandName(arg0 string) string {
+	name := filepath.Base(arg0)
+	if strings.HasPrefix(name, "kubectl-") {
+		return "kubectl " + strings.Replace(strings.TrimPrefix(name, "kubectl-"), "_", "-", -1)
+	}
+	return name
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/argocd"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/remote"
+)
+
+// applicationStore loads and saves ArgoCD Applications.
+type applicationStore interface {
+	// Get loads the referenced Application.
+	Get(ctx context.Context, ref appsv1alpha1.ApplicationReference) (*application.Application, error)
+
+	// Update saves the changes to the Application.
+	Update(ctx context.Context, app *application.Application) error
+
+	// Patch applies a JSON merge patch to the Application.
+	Patch(ctx context.Context, app *application.Application, patch []byte) error
+}
+
+// applicationsFor returns the store for the updater's Application.
+//
+// The client is used to load any Secrets that the ApplicationRef references,
+// and to access Applications in the local cluster.
+func (r *ImagePolicyArgoCDUpdateReconciler) applicationsFor(ctx context.Context, c client.Client, updater *appsv1alpha1.ImagePolicyArgoCDUpdate) (applicationStore, error) {
+	ref := updater.Spec.ApplicationRef
+	switch {
+	case ref.KubeConfig != nil && ref.API != nil:
+		return nil, withReason(appsv1alpha1.InvalidSpecReason, errors.New("applicationRef can't have both kubeConfig and api"))
+	case ref.API != nil:
+		secret, err := loadSecret(ctx, c, updater.Namespace, ref.API.SecretRef.Name)
+		if err != nil {
+			return nil, withReason(appsv1alpha1.ArgoCDAPIErrorReason, err)
+		}
+		apiClient, err := argocd.ClientFromSecret(secret, ref.API.Insecure)
+		if err != nil {
+			return nil, withReason(appsv1alpha1.ArgoCDAPIErrorReason, err)
+		}
+		return apiApplicationStore{client: apiClient, sync: ref.API.Sync}, nil
+	case ref.KubeConfig != nil:
+		secret, err := loadSecret(ctx, c, updater.Namespace, ref.KubeConfig.SecretRef.Name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				r.remoteClients.Forget(types.NamespacedName{Name: ref.KubeConfig.SecretRef.Name, Namespace: updater.Namespace})
+			}
+			return nil, withReason(appsv1alpha1.KubeConfigErrorReason, err)
+		}
+		remoteClient, err := r.remoteClients.ClientFor(secret, ref.KubeConfig.SecretKey())
+		if err != nil {
+			var connErr *remote.ConnectionError
+			if errors.As(err, &connErr) {
+				return nil, withReason(appsv1alpha1.ClusterConnectionFailedReason, err)
+			}
+			return nil, withReason(appsv1alpha1.KubeConfigErrorReason, err)
+		}
+		return kubeApplicationStore{client: remoteClient, errorReason: appsv1alpha1.ClusterConnectionFailedReason}, nil
+	}
+	return kubeApplicationStore{client: c}, nil
+}
+
+func loadSecret(ctx context.Context, c client.Client, ns, name string) (*corev1.Secret, error) {
+	secretName := types.NamespacedName{Name: name, Namespace: ns}
+	var secret corev1.Secret
+	if err := c.Get(ctx, secretName, &secret); err != nil {
+		return nil, fmt.Errorf("failed to load the Secret %s: %w", secretName, err)
+	}
+	return &secret, nil
+}
+
+// kubeApplicationStore accesses Applications through the Kubernetes API.
+type kubeApplicationStore struct {
+	client client.Client
+	// errorReason is associated with errors other than NotFound.
+	errorReason string
+}
+
+func (s kubeApplicationStore) Get(ctx context.Context, ref appsv1alpha1.ApplicationReference) (*application.Application, error) {
+	obj := application.NewObject()
+	appName := types.NamespacedName{
+		Name:      ref.Name,
+		Namespace: ref.Namespace,
+	}
+	if err := s.client.Get(ctx, appName, obj); err != nil {
+		return nil, s.wrap(err)
+	}
+	return application.New(obj)
+}
+
+func (s kubeApplicationStore) Update(ctx context.Context, app *application.Application) error {
+	if err := app.Apply(); err != nil {
+		return err
+	}
+	return s.wrap(s.client.Update(ctx, app.Object))
+}
+
+func (s kubeApplicationStore) Patch(ctx context.Context, app *application.Application, patch []byte) error {
+	return s.wrap(s.client.Patch(ctx, app.Object, client.RawPatch(types.MergePatchType, patch)))
+}
+
+func (s kubeApplicationStore) wrap(err error) error {
+	if err == nil || s.errorReason == "" || apierrors.IsNotFound(err) {
+		return err
+	}
+	return withReason(s.errorReason, err)
+}
+
+// apiApplicationStore accesses Applications through the ArgoCD API server.
+type apiApplicationStore struct {
+	client *argocd.Client
+	sync   bool
+}
+
+func (s apiApplicationStore) Get(ctx context.Context, ref appsv1alpha1.ApplicationReference) (*application.Application, error) {
+	obj, err := s.client.GetApplication(ctx, ref.Namespace, ref.Name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		return nil, withReason(appsv1alpha1.ArgoCDAPIErrorReason, err)
+	}
+	return application.New(obj)
+}
+
+// Update patches the fields of the Application's spec that changed, and its
+// annotations, in a single JSON merge patch.
+//
+// The patch has the resourceVersion that was read, so a concurrent change to
+// the Application is a conflict rather than overwritten.
+func (s apiApplicationStore) Update(ctx context.Context, app *application.Application) error {
+	loaded := app.Object.DeepCopy()
+	if err := app.Apply(); err != nil {
+		return err
+	}
+	data, err := client.MergeFromWithOptions(loaded, client.MergeFromWithOptimisticLock{}).Data(app.Object)
+	if err != nil {
+		return fmt.Errorf("failed to create the patch for Application %s: %w", app.Object.GetName(), err)
+	}
+	patch := map[string]interface{}{}
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return fmt.Errorf("failed to read the patch for Application %s: %w", app.Object.GetName(), err)
+	}
+	// The annotations are set on the object before it's saved, so they are
+	// always sent.
+	if annotations := app.Object.GetAnnotations(); len(annotations) > 0 {
+		if err := unstructured.SetNestedStringMap(patch, annotations, "metadata", "annotations"); err != nil {
+			return fmt.Errorf("failed to add the annotations to the patch: %w", err)
+		}
+	}
+	data, err = json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the patch: %w", err)
+	}
+	if err := s.Patch(ctx, app, data); err != nil {
+		return err
+	}
+	if s.sync {
+		if err := s.client.SyncApplication(ctx, app.Object.GetNamespace(), app.Object.GetName()); err != nil {
+			return withReason(appsv1alpha1.ArgoCDAPIErrorReason, err)
+		}
+	}
+	return nil
+}
+
+func (s apiApplicationStore) Patch(ctx context.Context, app *application.Application, patch []byte) error {
+	if err := s.client.PatchApplication(ctx, app.Object.GetNamespace(), app.Object.GetName(), patch); err != nil {
+		return withReason(appsv1alpha1.ArgoCDAPIErrorReason, err)
+	}
+	return nil
+}
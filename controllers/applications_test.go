@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/argocd"
+)
+
+func TestAPIApplicationStoreUpdate(t *testing.T) {
+	var patches []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Patch string `json:"patch"`
+		}
+		if r.Method != http.MethodPatch {
+			http.Error(w, "not implemented", http.StatusNotImplemented)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		patches = append(patches, body.Patch)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer api.Close()
+	store := apiApplicationStore{client: argocd.NewClient(api.URL, "test-token", api.Client())}
+	obj := makeTestApplication("bigkevmcd/go-demo:v1.0.0")
+	obj.SetResourceVersion("5")
+	app, err := application.New(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.Spec.Source.Kustomize.Images = application.KustomizeImages{"bigkevmcd/go-demo:v1.1.0"}
+	app.Object.SetAnnotations(map[string]string{"image.example.com/updated": "true"})
+	if err := store.Update(context.Background(), app); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(patches) != 1 {
+		t.Fatalf("got %d patches, want 1", len(patches))
+	}
+	got := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(patches[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": "5",
+			"annotations":     map[string]interface{}{"image.example.com/updated": "true"},
+		},
+		"spec": map[string]interface{}{
+			"source": map[string]interface{}{
+				"kustomize": map[string]interface{}{"images": []interface{}{"bigkevmcd/go-demo:v1.1.0"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("patch failed diff -want +got:\n%s", diff)
+	}
+}
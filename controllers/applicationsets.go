@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+// applicationSetTarget updates the template sources of an ApplicationSet.
+//
+// The ApplicationSet is accessed as an Unstructured object, and the template
+// is read into the application package's view.
+//
+// ApplicationSets are not watched, because the ApplicationSet API is optional,
+// so edits to them are picked up when the updaters are next reconciled.
+type applicationSetTarget struct {
+	client client.Client
+	app    *application.Application
+	// loaded is the ApplicationSet as it was read, the changes are patched
+	// from it.
+	loaded *unstructured.Unstructured
+}
+
+func loadApplicationSet(ctx context.Context, c client.Client, ref appsv1alpha1.ApplicationSetReference) (*applicationSetTarget, error) {
+	obj := application.NewApplicationSetObject()
+	if err := c.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}, obj); err != nil {
+		return nil, err
+	}
+	template, _, err := unstructured.NestedMap(obj.Object, "spec", "template", "spec")
+	if err != nil {
+		return nil, withReason(appsv1alpha1.InvalidSpecReason, fmt.Errorf("failed to read the template of ApplicationSet %s/%s: %w", ref.Namespace, ref.Name, err))
+	}
+	if template["source"] == nil && template["sources"] == nil {
+		return nil, withReason(appsv1alpha1.InvalidSpecReason, errors.New("the ApplicationSet template has no source"))
+	}
+	app, err := application.NewTemplate(obj)
+	if err != nil {
+		return nil, withReason(appsv1alpha1.InvalidSpecReason, err)
+	}
+	return &applicationSetTarget{client: c, app: app, loaded: obj.DeepCopy()}, nil
+}
+
+func (t *applicationSetTarget) Spec() *application.ApplicationSpec {
+	return &t.app.Spec
+}
+
+func (t *applicationSetTarget) Object() metav1.Object {
+	return t.app.Object
+}
+
+// Save writes the fields of the template that changed, other fields are left
+// as they are.
+//
+// The changes are sent as a JSON merge patch, with the resourceVersion that
+// was read, so that a concurrent edit is a conflict rather than overwritten.
+func (t *applicationSetTarget) Save(ctx context.Context) error {
+	if err := t.app.Apply(); err != nil {
+		return err
+	}
+	return t.client.Patch(ctx, t.app.Object, client.MergeFromWithOptions(t.loaded, client.MergeFromWithOptimisticLock{}))
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+var testApplicationSetRef = appsv1alpha1.ApplicationSetReference{Name: "go-demo-applications", Namespace: "argocd"}
+
+func TestApplicationSetSave(t *testing.T) {
+	c := newApplicationSetClient(t)
+	target, err := loadApplicationSet(context.Background(), c, testApplicationSetRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target.Spec().Source.Kustomize.Images = application.KustomizeImages{"bigkevmcd/go-demo:v1.1.0"}
+	if err := target.Save(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := loadTestApplicationSet(t, c)
+	images, _, err := unstructured.NestedStringSlice(saved.Object, "spec", "template", "spec", "source", "kustomize", "images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"bigkevmcd/go-demo:v1.1.0"}, images); diff != "" {
+		t.Fatalf("images failed diff -want +got:\n%s", diff)
+	}
+	generators, _, err := unstructured.NestedSlice(saved.Object, "spec", "generators")
+	if err != nil || len(generators) != 1 {
+		t.Fatalf("got generators %#v, want them left as they were", generators)
+	}
+}
+
+func TestApplicationSetSaveWithConcurrentChanges(t *testing.T) {
+	c := newApplicationSetClient(t)
+	target, err := loadApplicationSet(context.Background(), c, testApplicationSetRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := loadTestApplicationSet(t, c)
+	edited.SetLabels(map[string]string{"edited": "true"})
+	if err := c.Update(context.Background(), edited); err != nil {
+		t.Fatal(err)
+	}
+
+	target.Spec().Source.Kustomize.Images = application.KustomizeImages{"bigkevmcd/go-demo:v1.1.0"}
+	err = target.Save(context.Background())
+
+	if !apierrors.IsConflict(err) {
+		t.Fatalf("got error %v, want a conflict", err)
+	}
+}
+
+// newApplicationSetClient returns a client with the test ApplicationSet, it is
+// created so that it has a resourceVersion.
+func newApplicationSetClient(t *testing.T) client.Client {
+	t.Helper()
+	c := fake.NewFakeClientWithScheme(newTestScheme(t))
+	if err := c.Create(context.Background(), makeTestApplicationSet()); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func makeTestApplicationSet() *unstructured.Unstructured {
+	appSet := application.NewApplicationSetObject()
+	appSet.SetName(testApplicationSetRef.Name)
+	appSet.SetNamespace(testApplicationSetRef.Namespace)
+	appSet.Object["spec"] = map[string]interface{}{
+		"generators": []interface{}{
+			map[string]interface{}{"list": map[string]interface{}{"elements": []interface{}{}}},
+		},
+		"template": map[string]interface{}{
+			"spec": map[string]interface{}{
+				"source": map[string]interface{}{
+					"repoURL": "https://github.com/bigkevmcd/go-demo.git",
+					"kustomize": map[string]interface{}{
+						"images": []interface{}{"bigkevmcd/go-demo:v1.0.0"},
+					},
+				},
+			},
+		},
+	}
+	return appSet
+}
+
+func loadTestApplicationSet(t *testing.T, c client.Client) *unstructured.Unstructured {
+	t.Helper()
+	appSet := application.NewApplicationSetObject()
+	if err := c.Get(context.Background(), types.NamespacedName{Name: testApplicationSetRef.Name, Namespace: testApplicationSetRef.Namespace}, appSet); err != nil {
+		t.Fatal(err)
+	}
+	return appSet
+}
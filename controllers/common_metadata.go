@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/update"
+)
+
+// commonMetadataFinalizer is added to updaters that write Kustomize
+// commonLabels or commonAnnotations, so that they can be removed when the
+// updater is deleted.
+const commonMetadataFinalizer = "apps.bigkevmcd.com/common-metadata"
+
+// hasCommonMetadata returns true if the updater writes Kustomize commonLabels
+// or commonAnnotations.
+func hasCommonMetadata(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) bool {
+	labels, annotations := kustomizeStrategy(updater).CommonMetadataKeys()
+	return len(labels) > 0 || len(annotations) > 0
+}
+
+func kustomizeStrategy(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) *appsv1alpha1.KustomizeStrategy {
+	if updater.Spec.Strategy == nil {
+		return nil
+	}
+	return updater.Spec.Strategy.Kustomize
+}
+
+// syncFinalizer adds the finalizer to updaters that need to clean up, and
+// removes it from those that don't.
+func (r *ImagePolicyArgoCDUpdateReconciler) syncFinalizer(ctx context.Context, updater *appsv1alpha1.ImagePolicyArgoCDUpdate) error {
+	has := containsString(updater.GetFinalizers(), commonMetadataFinalizer)
+	needs := hasCommonMetadata(updater)
+	switch {
+	case needs && !has:
+		controllerutil.AddFinalizer(updater, commonMetadataFinalizer)
+	case !needs && has:
+		controllerutil.RemoveFinalizer(updater, commonMetadataFinalizer)
+	default:
+		return nil
+	}
+	return r.Update(ctx, updater)
+}
+
+// finalize removes the commonLabels and commonAnnotations that the updater
+// added to its target, and then removes the finalizer so that the updater can
+// be deleted.
+//
+// If the target can't be cleaned up because of a permission or configuration
+// error, e.g. the updater's access was revoked, the error is recorded and the
+// finalizer is removed anyway, so that deleting the updater doesn't hang.
+func (r *ImagePolicyArgoCDUpdateReconciler) finalize(ctx context.Context, logger logger, c client.Client, updater *appsv1alpha1.ImagePolicyArgoCDUpdate) (ctrl.Result, error) {
+	if !containsString(updater.GetFinalizers(), commonMetadataFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	// Only the target's own NotFound is unwrapped, a missing Secret is a
+	// configuration error.
+	target, err := r.loadTarget(ctx, c, updater)
+	if err != nil && !apierrors.IsNotFound(err) {
+		if !r.abandonCleanup(ctx, logger, updater, err) {
+			logger.error(err, "failed to load the update target for cleanup")
+			return ctrl.Result{}, err
+		}
+		target = nil
+	}
+	if target != nil {
+		// If the selected source no longer exists, there is nothing to
+		// remove.
+		src, err := sourceFor(target, updater)
+		if err != nil {
+			logger.info("not removing the common metadata", "reason", err.Error())
+		} else if err := removeCommonMetadata(ctx, target, src, kustomizeStrategy(updater)); err != nil {
+			if !r.abandonCleanup(ctx, logger, updater, err) {
+				logger.error(err, "failed to remove the common metadata")
+				return ctrl.Result{}, err
+			}
+		} else {
+			logger.info("removed the common metadata from the update target")
+		}
+	}
+
+	controllerutil.RemoveFinalizer(updater, commonMetadataFinalizer)
+	if err := r.Update(ctx, updater); err != nil {
+		logger.error(err, "failed to remove the finalizer")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// abandonCleanup records a cleanup error that retrying won't fix, permission
+// and configuration errors, in the updater's status and returns true, so that
+// the updater can be deleted without removing its common metadata.
+//
+// Other errors are retried.
+func (r *ImagePolicyArgoCDUpdateReconciler) abandonCleanup(ctx context.Context, logger logger, updater *appsv1alpha1.ImagePolicyArgoCDUpdate, err error) bool {
+	if classifyError(err) == appsv1alpha1.TransientError {
+		return false
+	}
+	logger.error(err, "not removing the common metadata, the update target can't be cleaned up")
+	r.recordFailure(ctx, logger, updater, reasonOf(err, failureReason(err)), fmt.Errorf("the common metadata was not removed: %w", err))
+	return true
+}
+
+func removeCommonMetadata(ctx context.Context, target updateTarget, src *application.ApplicationSource, strategy *appsv1alpha1.KustomizeStrategy) error {
+	labels, annotations := strategy.CommonMetadataKeys()
+	if len(labels) == 0 && len(annotations) == 0 {
+		return nil
+	}
+	update.RemoveCommonLabels(src, labels)
+	update.RemoveCommonAnnotations(src, annotations)
+	return target.Save(ctx)
+}
+
+// updateErrorReason returns the reason to record for an error applying the
+// update strategy.
+func updateErrorReason(err error) string {
+	var tmplErr *update.TemplateError
+	if errors.As(err, &tmplErr) {
+		return appsv1alpha1.TemplateErrorReason
+	}
+	return appsv1alpha1.InvalidSpecReason
+}
+
+func containsString(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
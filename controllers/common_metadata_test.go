@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+func TestFinalizeWhenTheTargetCantBeLoaded(t *testing.T) {
+	finalizeTests := []struct {
+		name          string
+		kubeConfig    bool
+		getErr        error
+		wantFinalizer bool
+		wantReason    string
+		wantMessage   string
+	}{
+		{
+			name:        "deleted kubeconfig Secret",
+			kubeConfig:  true,
+			wantReason:  appsv1alpha1.KubeConfigErrorReason,
+			wantMessage: `the common metadata was not removed: failed to load the Secret updaters/remote-cluster: secrets "remote-cluster" not found`,
+		},
+		{
+			name:        "access revoked",
+			getErr:      apierrors.NewForbidden(application.GroupVersionKind.GroupVersion().WithResource("applications").GroupResource(), argoAppName, errors.New("access denied")),
+			wantReason:  appsv1alpha1.PermissionDeniedReason,
+			wantMessage: `the common metadata was not removed: applications.argoproj.io "my-demo-app" is forbidden: access denied`,
+		},
+		{
+			name:          "transient error",
+			getErr:        apierrors.NewServiceUnavailable("the API server is restarting"),
+			wantFinalizer: true,
+		},
+	}
+
+	for _, tt := range finalizeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			updater := makeTestUpdater("go-demo-updater", "go-demo")
+			updater.Spec.Strategy = &appsv1alpha1.UpdateStrategy{
+				Kustomize: &appsv1alpha1.KustomizeStrategy{CommonLabels: map[string]string{"app.kubernetes.io/version": "{{ .Tag }}"}},
+			}
+			if tt.kubeConfig {
+				updater.Spec.ApplicationRef.KubeConfig = &appsv1alpha1.KubeConfigReference{SecretRef: corev1.LocalObjectReference{Name: "remote-cluster"}}
+			}
+			updater.Finalizers = []string{commonMetadataFinalizer}
+			now := metav1.Now()
+			updater.DeletionTimestamp = &now
+			c := newApplicationsClient(t, updater, makeTestApplication("bigkevmcd/go-demo:v1.0.0"))
+			c.getErr = tt.getErr
+			r := newTestReconciler(c)
+
+			_, err := r.Reconcile(reconcileRequest(updater))
+
+			if tt.wantFinalizer {
+				if err == nil {
+					t.Fatal("the transient error was not retried")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			var deleted appsv1alpha1.ImagePolicyArgoCDUpdate
+			if err := c.Get(context.Background(), types.NamespacedName{Name: updater.Name, Namespace: updater.Namespace}, &deleted); err != nil {
+				t.Fatal(err)
+			}
+			if has := containsString(deleted.Finalizers, commonMetadataFinalizer); has != tt.wantFinalizer {
+				t.Fatalf("got finalizer %v, want %v", has, tt.wantFinalizer)
+			}
+			if tt.wantFinalizer {
+				return
+			}
+			assertCondition(t, &deleted, appsv1alpha1.ReadyCondition, corev1.ConditionFalse, tt.wantReason, tt.wantMessage)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+)
+
+// setBlockedCondition records a condition that blocks the latest image from
+// being applied.
+//
+// If blocked is nil, an existing condition is set to False with the message,
+// otherwise the condition is only added when the image is blocked.
+func setBlockedCondition(updater *appsv1alpha1.ImagePolicyArgoCDUpdate, conditionType, reason string, blocked error, message string) {
+	if blocked != nil {
+		setCondition(updater, conditionType, corev1.ConditionTrue, reason, blocked.Error())
+		return
+	}
+	if appsv1alpha1.FindCondition(updater.Status.Conditions, conditionType) == nil {
+		return
+	}
+	setCondition(updater, conditionType, corev1.ConditionFalse, appsv1alpha1.ReconciliationSucceededReason, message)
+}
+
+// setCondition adds or replaces a condition in the updater's status.
+func setCondition(updater *appsv1alpha1.ImagePolicyArgoCDUpdate, conditionType string, status corev1.ConditionStatus, reason, message string) {
+	updater.Status.Conditions = appsv1alpha1.SetCondition(updater.Status.Conditions, appsv1alpha1.Condition{
+		Type:    conditionType,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+}
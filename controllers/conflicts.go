@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/argocd"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/remote"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/selection"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/update"
+)
+
+// conflict is a group of updaters for the same target that write images with
+// the same name.
+//
+// The updaters can be reconciled separately, if they access the target with
+// different credentials.
+type conflict struct {
+	imageName string
+	updaters  []*appsv1alpha1.ImagePolicyArgoCDUpdate
+}
+
+// winner returns the updater with the highest priority, or nil if the highest
+// priority is shared.
+func (c conflict) winner() *appsv1alpha1.ImagePolicyArgoCDUpdate {
+	sorted := append([]*appsv1alpha1.ImagePolicyArgoCDUpdate{}, c.updaters...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Spec.Priority > sorted[j].Spec.Priority
+	})
+	if sorted[0].Spec.Priority == sorted[1].Spec.Priority {
+		return nil
+	}
+	return sorted[0]
+}
+
+// findConflicts groups the updaters by the source that they write to, and
+// the name of their latest image, and returns the groups with more than one
+// updater.
+//
+// Updaters with no image name, or no source, are ignored.
+func findConflicts(updaters []*appsv1alpha1.ImagePolicyArgoCDUpdate, imageNames []string, sources []int) []conflict {
+	type key struct {
+		source int
+		name   string
+	}
+	byKey := map[key][]*appsv1alpha1.ImagePolicyArgoCDUpdate{}
+	var keys []key
+	for i, updater := range updaters {
+		k := key{source: sources[i], name: imageNames[i]}
+		if k.name == "" || k.source < 0 {
+			continue
+		}
+		if _, ok := byKey[k]; !ok {
+			keys = append(keys, k)
+		}
+		byKey[k] = append(byKey[k], updater)
+	}
+	var conflicts []conflict
+	for _, k := range keys {
+		if len(byKey[k]) > 1 {
+			conflicts = append(conflicts, conflict{imageName: k.name, updaters: byKey[k]})
+		}
+	}
+	return conflicts
+}
+
+// selectedSources returns the index of the target's source that each of the
+// updaters writes to.
+//
+// Updaters whose source can't be selected have the index -1, the error is
+// reported when the updater is reconciled.
+func selectedSources(target updateTarget, updaters []*appsv1alpha1.ImagePolicyArgoCDUpdate) []int {
+	sources := make([]int, len(updaters))
+	for i, updater := range updaters {
+		index, err := target.Spec().SelectSource(updater.Spec.Source)
+		if err != nil {
+			index = -1
+		}
+		sources[i] = index
+	}
+	return sources
+}
+
+// targetIdentity returns the cluster and name of the resource that the
+// updater writes to, which is the same for all the updaters that write to it,
+// whichever credentials they use.
+//
+// Remote clusters are identified by the API server in the kubeconfig, or the
+// ArgoCD API server, that the updater's Secret references. The Secret is read
+// with the updater's credentials, as it is when the target is loaded.
+func (r *ImagePolicyArgoCDUpdateReconciler) targetIdentity(ctx context.Context, updater *appsv1alpha1.ImagePolicyArgoCDUpdate) (string, error) {
+	name := appsv1alpha1.TargetName(updater)
+	ref := updater.Spec.ApplicationRef
+	if ref == nil || (ref.API == nil && ref.KubeConfig == nil) {
+		return name, nil
+	}
+	kubeClient, err := r.clientFor(updater)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case ref.API != nil:
+		secret, err := loadSecret(ctx, kubeClient, updater.Namespace, ref.API.SecretRef.Name)
+		if err != nil {
+			return "", err
+		}
+		server := strings.TrimSuffix(strings.TrimSpace(string(secret.Data[argocd.ServerKey])), "/")
+		return "argocd=" + server + "," + name, nil
+	case ref.KubeConfig != nil:
+		secret, err := loadSecret(ctx, kubeClient, updater.Namespace, ref.KubeConfig.SecretRef.Name)
+		if err != nil {
+			return "", err
+		}
+		cfg, err := remote.RESTConfigFromSecret(secret, ref.KubeConfig.SecretKey())
+		if err != nil {
+			return "", err
+		}
+		return "cluster=" + strings.TrimSuffix(cfg.Host, "/") + "," + name, nil
+	}
+	return name, nil
+}
+
+// peerUpdaters returns the active updaters that write to the same resource as
+// the updater, but access it with other credentials, so that they are
+// reconciled separately from the updaters with the key.
+//
+// They are only used to find conflicts, their statuses are recorded when they
+// are reconciled.
+func (r *ImagePolicyArgoCDUpdateReconciler) peerUpdaters(ctx context.Context, logger logger, key string, updater *appsv1alpha1.ImagePolicyArgoCDUpdate) []*appsv1alpha1.ImagePolicyArgoCDUpdate {
+	name := appsv1alpha1.TargetName(updater)
+	if name == "" {
+		return nil
+	}
+	var updaterList appsv1alpha1.ImagePolicyArgoCDUpdateList
+	if err := r.List(ctx, &updaterList, client.MatchingFields{targetNameKey: name}); err != nil {
+		logger.error(err, "failed to list the updaters for the same resource")
+		return nil
+	}
+	identity, err := r.targetIdentity(ctx, updater)
+	if err != nil {
+		logger.error(err, "failed to identify the cluster of the update target")
+		return nil
+	}
+	var peers []*appsv1alpha1.ImagePolicyArgoCDUpdate
+	for i := range updaterList.Items {
+		peer := &updaterList.Items[i]
+		if updateTargetKey(peer) == key || peer.Spec.Suspend || !peer.DeletionTimestamp.IsZero() || !r.watchesNamespace(peer.Namespace) {
+			continue
+		}
+		// Peers whose cluster can't be identified are reported when they
+		// are reconciled.
+		if peerIdentity, err := r.targetIdentity(ctx, peer); err != nil || peerIdentity != identity {
+			continue
+		}
+		peers = append(peers, peer)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Namespace+"/"+peers[i].Name < peers[j].Namespace+"/"+peers[j].Name
+	})
+	return peers
+}
+
+// imageNames loads the ImagePolicy for each of the updaters, and returns the
+// name of the image that the updater applies.
+//
+// Updaters whose ImagePolicy can't be loaded have no name, the error is
+// reported when the updater is reconciled.
+func (r *ImagePolicyArgoCDUpdateReconciler) imageNames(ctx context.Context, updaters []*appsv1alpha1.ImagePolicyArgoCDUpdate) []string {
+	names := make([]string, len(updaters))
+	for i, updater := range updaters {
+		kubeClient, err := r.clientFor(updater)
+		if err != nil {
+			continue
+		}
+		imagePolicy, err := r.loadImagePolicy(ctx, kubeClient, updater.Namespace, updater.Spec.ImagePolicyRef)
+		if err != nil {
+			continue
+		}
+		if image, _ := selection.DesiredImage(updater, imagePolicy); image != "" {
+			names[i] = update.ParseImage(image).Name
+		}
+	}
+	return names
+}
+
+// resolveConflicts records the Conflict condition on the updaters, and returns
+// a failed result for the updaters that must not be applied.
+func resolveConflicts(updaters []*appsv1alpha1.ImagePolicyArgoCDUpdate, conflicts []conflict) map[*appsv1alpha1.ImagePolicyArgoCDUpdate]*updaterResult {
+	blocked := map[*appsv1alpha1.ImagePolicyArgoCDUpdate]*updaterResult{}
+	inConflict := map[*appsv1alpha1.ImagePolicyArgoCDUpdate]bool{}
+	for _, c := range conflicts {
+		winner := c.winner()
+		for _, updater := range c.updaters {
+			inConflict[updater] = true
+			others := otherUpdaterNames(c.updaters, updater)
+			if updater == winner {
+				setCondition(updater, appsv1alpha1.ConflictCondition, corev1.ConditionTrue, appsv1alpha1.PrecedenceReason,
+					fmt.Sprintf("image %s is also written by %s, this updater has the highest priority", c.imageName, others))
+				continue
+			}
+			message := fmt.Sprintf("image %s is also written by %s", c.imageName, others)
+			if winner != nil {
+				message += fmt.Sprintf(", %s/%s has a higher priority", winner.Namespace, winner.Name)
+			} else {
+				message += ", with the same priority"
+			}
+			setCondition(updater, appsv1alpha1.ConflictCondition, corev1.ConditionTrue, appsv1alpha1.ConflictReason, message)
+			res := &updaterResult{updater: updater}
+			res.setStatus(corev1.ConditionFalse, appsv1alpha1.ConflictReason, message)
+			blocked[updater] = res
+		}
+	}
+	for _, updater := range updaters {
+		if !inConflict[updater] {
+			setBlockedCondition(updater, appsv1alpha1.ConflictCondition, appsv1alpha1.ConflictReason, nil, "no other updater writes the same image")
+		}
+	}
+	return blocked
+}
+
+func otherUpdaterNames(updaters []*appsv1alpha1.ImagePolicyArgoCDUpdate, exclude *appsv1alpha1.ImagePolicyArgoCDUpdate) string {
+	var names []string
+	for _, updater := range updaters {
+		if updater != exclude {
+			names = append(names, updater.Namespace+"/"+updater.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+)
+
+func TestConflictWinner(t *testing.T) {
+	winnerTests := []struct {
+		name       string
+		priorities []int32
+		want       int
+	}{
+		{"highest priority", []int32{0, 10, 5}, 1},
+		{"shared highest priority", []int32{10, 10, 5}, -1},
+		{"shared lower priority", []int32{5, 10, 5}, 1},
+		{"no priorities", []int32{0, 0}, -1},
+	}
+
+	for _, tt := range winnerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := conflict{imageName: "bigkevmcd/go-demo"}
+			for i, priority := range tt.priorities {
+				updater := makeTestUpdater(string(rune('a'+i)), "go-demo")
+				updater.Spec.Priority = priority
+				c.updaters = append(c.updaters, updater)
+			}
+
+			winner := c.winner()
+
+			if tt.want < 0 {
+				if winner != nil {
+					t.Fatalf("got winner %s, want none", winner.Name)
+				}
+				return
+			}
+			if winner != c.updaters[tt.want] {
+				t.Fatalf("got winner %v, want %s", winner, c.updaters[tt.want].Name)
+			}
+		})
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	updaters := []*appsv1alpha1.ImagePolicyArgoCDUpdate{
+		makeTestUpdater("a", "go-demo"),
+		makeTestUpdater("b", "go-demo"),
+		makeTestUpdater("c", "go-demo"),
+		makeTestUpdater("d", "other"),
+		makeTestUpdater("e", "other"),
+		makeTestUpdater("f", "unknown"),
+		makeTestUpdater("g", "unknown"),
+	}
+	imageNames := []string{"bigkevmcd/go-demo", "bigkevmcd/go-demo", "bigkevmcd/go-demo", "bigkevmcd/other", "bigkevmcd/other", "", ""}
+	// c writes to another source, and e's source can't be selected.
+	sources := []int{0, 0, 1, 0, -1, 0, 0}
+
+	conflicts := findConflicts(updaters, imageNames, sources)
+
+	var got [][]string
+	for _, c := range conflicts {
+		names := []string{c.imageName}
+		for _, updater := range c.updaters {
+			names = append(names, updater.Name)
+		}
+		got = append(got, names)
+	}
+	want := [][]string{{"bigkevmcd/go-demo", "a", "b"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("failed to find the conflicts:\n%s", diff)
+	}
+}
+
+func TestResolveConflicts(t *testing.T) {
+	high, low, other := makeTestUpdater("high", "go-demo"), makeTestUpdater("low", "go-demo"), makeTestUpdater("other", "other")
+	high.Spec.Priority = 10
+	// other had a conflict that has been resolved.
+	setCondition(other, appsv1alpha1.ConflictCondition, corev1.ConditionTrue, appsv1alpha1.ConflictReason, "image bigkevmcd/other is also written by updaters/removed")
+	updaters := []*appsv1alpha1.ImagePolicyArgoCDUpdate{high, low, other}
+
+	blocked := resolveConflicts(updaters, []conflict{{imageName: "bigkevmcd/go-demo", updaters: []*appsv1alpha1.ImagePolicyArgoCDUpdate{high, low}}})
+
+	if len(blocked) != 1 || blocked[low] == nil {
+		t.Fatalf("got blocked updaters %v, want only low", blocked)
+	}
+	wantMessage := "image bigkevmcd/go-demo is also written by updaters/high, updaters/high has a higher priority"
+	if res := blocked[low]; res.status != corev1.ConditionFalse || res.reason != appsv1alpha1.ConflictReason || res.message != wantMessage {
+		t.Fatalf("got result %s %s %q, want False Conflict %q", res.status, res.reason, res.message, wantMessage)
+	}
+	assertCondition(t, high, appsv1alpha1.ConflictCondition, corev1.ConditionTrue, appsv1alpha1.PrecedenceReason,
+		"image bigkevmcd/go-demo is also written by updaters/low, this updater has the highest priority")
+	assertCondition(t, low, appsv1alpha1.ConflictCondition, corev1.ConditionTrue, appsv1alpha1.ConflictReason, wantMessage)
+	assertCondition(t, other, appsv1alpha1.ConflictCondition, corev1.ConditionFalse, appsv1alpha1.ReconciliationSucceededReason,
+		"no other updater writes the same image")
+}
+
+func TestResolveConflictsWithSharedPriority(t *testing.T) {
+	a, b := makeTestUpdater("a", "go-demo"), makeTestUpdater("b", "go-demo")
+
+	blocked := resolveConflicts([]*appsv1alpha1.ImagePolicyArgoCDUpdate{a, b}, []conflict{{imageName: "bigkevmcd/go-demo", updaters: []*appsv1alpha1.ImagePolicyArgoCDUpdate{a, b}}})
+
+	if len(blocked) != 2 {
+		t.Fatalf("got %d blocked updaters, want 2", len(blocked))
+	}
+	assertCondition(t, a, appsv1alpha1.ConflictCondition, corev1.ConditionTrue, appsv1alpha1.ConflictReason,
+		"image bigkevmcd/go-demo is also written by updaters/b, with the same priority")
+	assertCondition(t, b, appsv1alpha1.ConflictCondition, corev1.ConditionTrue, appsv1alpha1.ConflictReason,
+		"image bigkevmcd/go-demo is also written by updaters/a, with the same priority")
+}
+
+func TestReconcileFindsConflictsWithOtherServiceAccounts(t *testing.T) {
+	teamA := makeTestUpdater("team-a", "go-demo")
+	teamA.Spec.ServiceAccountName = "team-a"
+	teamA.Spec.Priority = 10
+	teamB := makeTestUpdater("team-b", "go-demo-rc")
+	teamB.Spec.ServiceAccountName = "team-b"
+	c := newApplicationsClient(t,
+		teamA, teamB,
+		makeTestPolicy("go-demo", "bigkevmcd/go-demo", "v1.1.0"),
+		makeTestPolicy("go-demo-rc", "bigkevmcd/go-demo", "v1.2.0-rc1"),
+		makeTestApplication("bigkevmcd/go-demo:v1.0.0"),
+	)
+	r := newTestReconciler(c)
+	if updateTargetKey(teamA) == updateTargetKey(teamB) {
+		t.Fatal("the updaters are reconciled together")
+	}
+
+	reconcileTarget(t, r, teamB)
+
+	if c.updates != 0 {
+		t.Fatalf("got %d updates to the Application, want 0", c.updates)
+	}
+	assertReady(t, c, "team-b", corev1.ConditionFalse, appsv1alpha1.ConflictReason,
+		"image bigkevmcd/go-demo is also written by updaters/team-a, updaters/team-a has a higher priority")
+
+	reconcileTarget(t, r, teamA)
+
+	assertApplicationImages(t, c, "bigkevmcd/go-demo:v1.1.0")
+	updater := assertReady(t, c, "team-a", corev1.ConditionTrue, appsv1alpha1.ReconciliationSucceededReason, "applied image bigkevmcd/go-demo:v1.1.0")
+	assertCondition(t, updater, appsv1alpha1.ConflictCondition, corev1.ConditionTrue, appsv1alpha1.PrecedenceReason,
+		"image bigkevmcd/go-demo is also written by updaters/team-b, this updater has the highest priority")
+}
+
+func TestPeerUpdatersMatchesTheCluster(t *testing.T) {
+	local := makeTestUpdater("local", "go-demo")
+	remote := makeTestUpdater("remote", "go-demo")
+	remote.Spec.ApplicationRef.KubeConfig = &appsv1alpha1.KubeConfigReference{SecretRef: corev1.LocalObjectReference{Name: "remote-cluster"}}
+	sameRemote := makeTestUpdater("same-remote", "go-demo")
+	sameRemote.Spec.ApplicationRef.KubeConfig = &appsv1alpha1.KubeConfigReference{SecretRef: corev1.LocalObjectReference{Name: "same-remote-cluster"}}
+	otherApp := makeTestUpdater("other-app", "go-demo")
+	otherApp.Spec.ApplicationRef.Name = "other-app"
+	c := newApplicationsClient(t, local, remote, sameRemote, otherApp,
+		makeKubeConfigSecret("remote-cluster", "https://remote.example.com"),
+		makeKubeConfigSecret("same-remote-cluster", "https://remote.example.com/"))
+	r := newTestReconciler(c)
+
+	peers := r.peerUpdaters(context.Background(), newLogger(r.Log), updateTargetKey(remote), remote)
+
+	if len(peers) != 1 || peers[0].Name != "same-remote" {
+		t.Fatalf("got peers %v, want only same-remote", peers)
+	}
+	if peers := r.peerUpdaters(context.Background(), newLogger(r.Log), updateTargetKey(local), local); len(peers) != 0 {
+		t.Fatalf("got peers %v for the local Application, want none", peers)
+	}
+}
+
+func makeKubeConfigSecret(name, server string) *corev1.Secret {
+	kubeConfig := `apiVersion: v1
+kind: Config
+clusters:
+- name: remote
+  cluster:
+    server: ` + server + `
+contexts:
+- name: remote
+  context:
+    cluster: remote
+    user: remote
+current-context: remote
+users:
+- name: remote
+  user:
+    token: test-token
+`
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: updaterNamespace},
+		Data:       map[string][]byte{appsv1alpha1.DefaultKubeConfigSecretKey: []byte(kubeConfig)},
+	}
+}
+
+func assertCondition(t *testing.T, updater *appsv1alpha1.ImagePolicyArgoCDUpdate, conditionType string, status corev1.ConditionStatus, reason, message string) {
+	t.Helper()
+	c := appsv1alpha1.FindCondition(updater.Status.Conditions, conditionType)
+	if c == nil {
+		t.Fatalf("%s has no %s condition", updater.Name, conditionType)
+	}
+	want := []string{string(status), reason, message}
+	if diff := cmp.Diff(want, []string{string(c.Status), c.Reason, c.Message}); diff != "" {
+		t.Fatalf("%s has the wrong %s condition:\n%s", updater.Name, conditionType, diff)
+	}
+}
@@ -0,0 +1,11 @@
+package controllers
+
+import (
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+)
+
+// setDowngradeBlocked records the DowngradeBlocked condition in the updater's
+// status.
+func setDowngradeBlocked(updater *appsv1alpha1.ImagePolicyArgoCDUpdate, blocked error) {
+	setBlockedCondition(updater, appsv1alpha1.DowngradeBlockedCondition, appsv1alpha1.DowngradeBlockedReason, blocked, "the latest image is not a downgrade")
+}
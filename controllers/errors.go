@@ -0,0 +1,32 @@
+package controllers
+
+import "errors"
+
+// reasonError associates an error with the reason that is recorded in the
+// updater's Ready condition.
+type reasonError struct {
+	reason string
+	err    error
+}
+
+func (e reasonError) Error() string {
+	return e.err.Error()
+}
+
+func (e reasonError) Unwrap() error {
+	return e.err
+}
+
+func withReason(reason string, err error) error {
+	return reasonError{reason: reason, err: err}
+}
+
+// reasonOf returns the reason associated with the error, or the fallback if
+// there is none.
+func reasonOf(err error, fallback string) string {
+	var re reasonError
+	if errors.As(err, &re) {
+		return re.reason
+	}
+	return fallback
+}
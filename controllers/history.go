@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/imagepolicy"
+)
+
+// recordImages records the ImagePolicy's latest image and the pinned image in
+// the updater's status, so that both are visible while an image is pinned.
+func recordImages(updater *appsv1alpha1.ImagePolicyArgoCDUpdate, imagePolicy *imagepolicy.ImagePolicy) {
+	updater.Status.LatestImage = imagePolicy.LatestImage
+	updater.Status.PinnedImage = updater.Spec.PinnedImage
+}
+
+// recordHistory adds the applied image to the start of the updater's history,
+// dropping the oldest entries beyond MaxImageHistory.
+func recordHistory(updater *appsv1alpha1.ImagePolicyArgoCDUpdate, image string, pinned bool, now time.Time) {
+	entry := appsv1alpha1.ImageHistoryEntry{Image: image, AppliedAt: metav1.NewTime(now), Pinned: pinned}
+	history := append([]appsv1alpha1.ImageHistoryEntry{entry}, updater.Status.History...)
+	if len(history) > appsv1alpha1.MaxImageHistory {
+		history = history[:appsv1alpha1.MaxImageHistory]
+	}
+	updater.Status.History = history
+}
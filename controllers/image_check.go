@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/registry"
+)
+
+// registryTimeout limits the time for each request to a registry, so that an
+// unresponsive registry doesn't block reconciliation.
+const registryTimeout = 30 * time.Second
+
+// checkImageExists confirms that the image's manifest is in the registry that
+// the cluster pulls from, if the updater has an ImageCheck.
+func (r *ImagePolicyArgoCDUpdateReconciler) checkImageExists(ctx context.Context, c client.Client, updater *appsv1alpha1.ImagePolicyArgoCDUpdate, image string) error {
+	if updater.Spec.ImageCheck == nil {
+		return nil
+	}
+	registryClient, ref, err := r.registryReference(ctx, c, updater, image)
+	if err != nil {
+		return err
+	}
+	_, err = registryClient.Head(ctx, ref)
+	if errors.Is(err, registry.ErrNotFound) {
+		return withReason(appsv1alpha1.ImageNotFoundReason, fmt.Errorf("image %s was not found in %s", image, ref.Registry))
+	}
+	if err != nil {
+		return withReason(appsv1alpha1.RegistryErrorReason, err)
+	}
+	return nil
+}
+
+// registryReference returns the reference to the image in the registry that
+// the cluster pulls from, and a registry client with the pull secrets from the
+// updater's ImageCheck.
+func (r *ImagePolicyArgoCDUpdateReconciler) registryReference(ctx context.Context, c client.Client, updater *appsv1alpha1.ImagePolicyArgoCDUpdate, image string) (*registry.Client, registry.Reference, error) {
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		return nil, ref, withReason(appsv1alpha1.InvalidSpecReason, err)
+	}
+	check := updater.Spec.ImageCheck
+	if check == nil {
+		return r.registryClient, ref, nil
+	}
+	if check.Mirror != "" {
+		ref = ref.WithRegistry(check.Mirror)
+	}
+	creds, err := loadPullSecrets(ctx, c, updater.Namespace, check.PullSecrets)
+	if err != nil {
+		return nil, ref, withReason(appsv1alpha1.RegistryErrorReason, err)
+	}
+	return r.registryClient.WithCredentials(creds), ref, nil
+}
+
+func loadPullSecrets(ctx context.Context, c client.Client, ns string, refs []corev1.LocalObjectReference) (registry.Credentials, error) {
+	creds := registry.Credentials{}
+	for _, ref := range refs {
+		secret, err := loadSecret(ctx, c, ns, ref.Name)
+		if err != nil {
+			return nil, err
+		}
+		data, ok := secret.Data[corev1.DockerConfigJsonKey]
+		if !ok {
+			return nil, fmt.Errorf("secret %s/%s has no key %q", ns, ref.Name, corev1.DockerConfigJsonKey)
+		}
+		parsed, err := registry.ParseDockerConfig(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the pull secret %s/%s: %w", ns, ref.Name, err)
+		}
+		creds.Merge(parsed)
+	}
+	return creds, nil
+}
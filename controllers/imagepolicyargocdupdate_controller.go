@@ -16,120 +16,457 @@ package controllers
 
 import (
 	"context"
+	"net/http"
+	"sort"
+	"time"
 
-	argov1alpha1 "github.com/argoproj/argo-cd/pkg/apis/application/v1alpha1"
-	imagev1alpha1 "github.com/fluxcd/image-reflector-controller/api/v1alpha1"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
-	"github.com/bigkevmcd/image-policy-argo-updater/pkg/update"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/imagepolicy"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/registry"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/remote"
 )
 
-const applicationKey = ".spec.application"
+const applicationKey = ".spec.target"
 const imagePolicyKey = ".spec.imagePolicy"
+const secretsKey = ".spec.secrets"
+const configMapsKey = ".spec.configMaps"
+const targetNameKey = ".spec.targetName"
 
 // ImagePolicyArgoCDUpdateReconciler reconciles a ImagePolicyArgoCDUpdate object
 type ImagePolicyArgoCDUpdateReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	Config *rest.Config
+	Mapper meta.RESTMapper
+
+	// Namespaces restricts the namespaces that updaters are reconciled in,
+	// if it's empty, updaters in all namespaces are reconciled.
+	Namespaces []string
+	// ApplicationNamespaces restricts the namespaces of the Applications and
+	// ApplicationSets that can be updated, if it's empty, any namespace can
+	// be updated.
+	ApplicationNamespaces []string
+
+	// MaxConcurrentReconciles is the number of update targets that can be
+	// reconciled at the same time, it defaults to 1.
+	MaxConcurrentReconciles int
+	// RateLimiter limits how often failed reconciles are retried, it
+	// defaults to the workqueue's default controller rate limiter.
+	RateLimiter ratelimiter.RateLimiter
+	// RequeueInterval is how long after a successful reconcile the update
+	// target is reconciled again, zero disables this.
+	RequeueInterval time.Duration
+
+	imagePolicies  imagepolicy.Reader
+	remoteClients  *remote.ClientCache
+	registryClient *registry.Client
+	now            func() time.Time
+	// newClient creates the clients that impersonate ServiceAccounts, if
+	// it's nil they're created with client.New.
+	newClient func(*rest.Config) (client.Client, error)
 }
 
 // +kubebuilder:rbac:groups=apps.bigkevmcd.com,resources=imagepolicyargocdupdates,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps.bigkevmcd.com,resources=imagepolicyargocdupdates/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=argoproj.io,resources=applications,verbs=get;patch;list;watch;update
+// +kubebuilder:rbac:groups=argoproj.io,resources=applicationsets,verbs=get;patch
 // +kubebuilder:rbac:groups=image.toolkit.fluxcd.io,resources=imagepolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=impersonate
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
 
+// Reconcile reconciles all the ImagePolicyArgoCDUpdates for an update target,
+// the request name is the key from updateTargetKey.
 func (r *ImagePolicyArgoCDUpdateReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
-	logger := newLogger(r.Log.WithValues("imagepolicyargocdupdate", req.NamespacedName))
-	logger.info("reconciling ImagePolicyArgoCDUpdate", "req", req)
+	logger := newLogger(r.Log.WithValues("target", req.Name))
+	logger.info("reconciling the ImagePolicyArgoCDUpdates for the target", "req", req)
 
-	var policy appsv1alpha1.ImagePolicyArgoCDUpdate
-	if err := r.Get(ctx, req.NamespacedName, &policy); err != nil {
-		// This ignores NotFound errors because retrying is unlikely to fix the
-		// problem.
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+	var updaterList appsv1alpha1.ImagePolicyArgoCDUpdateList
+	if err := r.List(ctx, &updaterList, client.MatchingFields{applicationKey: req.Name}); err != nil {
+		logger.error(err, "failed to list the update policies")
+		return ctrl.Result{}, err
 	}
-	logger.info("loaded the update policy", "policy", policy.Name)
 
-	argoApp, err := r.loadApplication(ctx, policy.Spec.ApplicationRef)
-	if err != nil {
-		logger.error(err, "referenced application does not exist")
-		// This ignores NotFound errors because retrying is unlikely to fix the
-		// problem.
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+	// The updaters are applied in a consistent order, so that the combined
+	// change doesn't depend on the order of the cache.
+	sort.Slice(updaterList.Items, func(i, j int) bool {
+		a, b := updaterList.Items[i], updaterList.Items[j]
+		return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+	})
+	var updaters []*appsv1alpha1.ImagePolicyArgoCDUpdate
+	for i := range updaterList.Items {
+		updater := &updaterList.Items[i]
+		// The cache can include namespaces that are only watched for their
+		// Applications.
+		if !r.watchesNamespace(updater.Namespace) {
+			continue
+		}
+		updaterLogger := loggerForUpdater(logger, updater)
+		if !updater.DeletionTimestamp.IsZero() {
+			kubeClient, err := r.clientFor(updater)
+			if err == nil {
+				_, err = r.finalize(ctx, updaterLogger, kubeClient, updater)
+			}
+			if err != nil {
+				updaterLogger.error(err, "failed to finalize the update policy")
+				return ctrl.Result{}, err
+			}
+			continue
+		}
+		if err := r.syncFinalizer(ctx, updater); err != nil {
+			updaterLogger.error(err, "failed to update the finalizers")
+			return ctrl.Result{}, err
+		}
+		updaters = append(updaters, updater)
+	}
+	if len(updaters) == 0 {
+		return ctrl.Result{}, nil
 	}
-	logger.info("loaded the application", "argoApp", argoApp)
+	logger.info("loaded the update policies", "count", len(updaters))
 
-	imagePolicy, err := r.loadImagePolicy(ctx, policy.Namespace, policy.Spec.ImagePolicyRef)
+	if err := r.checkTargetNamespace(updaters[0]); err != nil {
+		logger.error(err, "the update target is not in an allowed namespace")
+		for _, updater := range updaters {
+			r.recordFailure(ctx, logger, updater, appsv1alpha1.InvalidSpecReason, err)
+		}
+		// The spec needs to change to fix this, which will trigger a new
+		// reconciliation.
+		return ctrl.Result{}, nil
+	}
+
+	// All the updaters access the target in the same way, so any of them can
+	// be used to load and save it.
+	kubeClient, err := r.clientFor(updaters[0])
+	if err != nil {
+		logger.error(err, "failed to create a client for the update policy", "serviceAccountName", updaters[0].Spec.ServiceAccountName)
+		return ctrl.Result{}, err
+	}
+	target, err := r.loadTarget(ctx, kubeClient, updaters[0])
 	if err != nil {
-		logger.error(err, "referenced image policy does not exist")
-		// This ignores NotFound errors because retrying is unlikely to fix the
-		// problem.
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+		logger.error(err, "failed to load the update target")
+		reason := reasonOf(err, failureReason(err))
+		if apiStatusReason(err) == metav1.StatusReasonNotFound {
+			reason = appsv1alpha1.TargetNotFoundReason
+		}
+		results := make([]*updaterResult, len(updaters))
+		for i, updater := range updaters {
+			results[i] = &updaterResult{updater: updater, err: err}
+			results[i].fail(reason, err)
+		}
+		return r.recordResults(ctx, logger, results, r.now())
 	}
-	logger.info("loaded the image policy", "imagePolicy", imagePolicy.Name)
+	logger.info("loaded the update target")
 
-	if argoApp.Spec.Source.Kustomize == nil {
-		argoApp.Spec.Source.Kustomize = &argov1alpha1.ApplicationSourceKustomize{}
+	// Suspended updaters don't write images, so they can't conflict.
+	var active []*appsv1alpha1.ImagePolicyArgoCDUpdate
+	for _, updater := range updaters {
+		if !updater.Spec.Suspend {
+			active = append(active, updater)
+		}
 	}
-	update.OverrideImage(argoApp, argov1alpha1.KustomizeImage(imagePolicy.Status.LatestImage))
-	if err := r.Update(ctx, argoApp); err != nil {
-		logger.error(err, "failed to update the ArgoCD Application")
-		return ctrl.Result{}, err
+	peers := r.peerUpdaters(ctx, logger, req.Name, updaters[0])
+	candidates := append(append([]*appsv1alpha1.ImagePolicyArgoCDUpdate{}, active...), peers...)
+	conflicts := findConflicts(candidates, r.imageNames(ctx, candidates), selectedSources(target, candidates))
+	blocked := resolveConflicts(active, conflicts)
+	if len(conflicts) > 0 {
+		logger.info("updaters for the target write the same image", "conflicts", len(conflicts))
+	}
+	for _, updater := range updaters {
+		if updater.Spec.Suspend {
+			res := &updaterResult{updater: updater}
+			res.setStatus(corev1.ConditionFalse, appsv1alpha1.SuspendedReason, "the updater is suspended")
+			blocked[updater] = res
+		}
+	}
+
+	// The minInterval applies to the target, whichever updater changed it.
+	lastUpdate := lastTargetUpdate(append(append([]*appsv1alpha1.ImagePolicyArgoCDUpdate{}, updaters...), peers...))
+	now := r.now()
+	results := make([]*updaterResult, len(updaters))
+	changed := false
+	for i, updater := range updaters {
+		if res, ok := blocked[updater]; ok {
+			results[i] = res
+			continue
+		}
+		results[i] = r.reconcileUpdater(ctx, loggerForUpdater(logger, updater), target, updater, lastUpdate, now)
+		changed = changed || results[i].changed
+	}
+
+	if err := r.writeTarget(ctx, logger, target, changed); err != nil {
+		for _, res := range results {
+			if res.image == "" {
+				continue
+			}
+			res.fail(reasonOf(err, failureReason(err)), err)
+			res.err = err
+		}
+	} else if changed {
+		for _, res := range results {
+			if res.changed {
+				logger.info("updated the ArgoCD resource", "updater", res.updater.Name, "previousImage", res.previousImage, "newImage", res.image)
+				recordUpdate(res.updater, res.image, res.pinned, now)
+			}
+		}
 	}
-	logger.info("updated the ArgoCD application", "newImage", imagePolicy.Status.LatestImage)
-	return ctrl.Result{}, nil
+
+	return r.recordResults(ctx, logger, results, now)
 }
 
-func (r *ImagePolicyArgoCDUpdateReconciler) loadApplication(ctx context.Context, ref corev1.ObjectReference) (*argov1alpha1.Application, error) {
-	var argoApp argov1alpha1.Application
-	appName := types.NamespacedName{
-		Name:      ref.Name,
-		Namespace: ref.Namespace,
+// recordResults writes the updaters' statuses, and returns when the target
+// should be reconciled again.
+//
+// Failed updaters are retried according to their retry policy, rather than
+// returning the error, so that the retries depend on the class of error.
+func (r *ImagePolicyArgoCDUpdateReconciler) recordResults(ctx context.Context, logger logger, results []*updaterResult, now time.Time) (ctrl.Result, error) {
+	var result ctrl.Result
+	var resultErr error
+	for _, res := range results {
+		if res.err != nil {
+			scheduleRetry(res, now)
+			loggerForUpdater(logger, res.updater).info("retrying the failed reconciliation",
+				"class", res.updater.Status.Retry.Class, "failures", res.updater.Status.Retry.Failures, "retryAfter", res.requeueAfter)
+		} else if res.reason != "" {
+			clearRetry(res.updater)
+		}
+		if res.reason != "" {
+			if err := r.setReadiness(ctx, res.updater, res.status, res.reason, res.message); err != nil {
+				loggerForUpdater(logger, res.updater).error(err, "failed to update the status")
+				if resultErr == nil {
+					resultErr = err
+				}
+			}
+		}
+		if res.requeueAfter > 0 && (result.RequeueAfter == 0 || res.requeueAfter < result.RequeueAfter) {
+			result.RequeueAfter = res.requeueAfter
+		}
 	}
-	if err := r.Get(ctx, appName, &argoApp); err != nil {
-		return nil, err
+	if resultErr == nil && r.RequeueInterval > 0 && (result.RequeueAfter == 0 || r.RequeueInterval < result.RequeueAfter) {
+		result.RequeueAfter = r.RequeueInterval
 	}
-	return &argoApp, nil
+	return result, resultErr
 }
 
-func (r *ImagePolicyArgoCDUpdateReconciler) loadImagePolicy(ctx context.Context, ns string, ref corev1.LocalObjectReference) (*imagev1alpha1.ImagePolicy, error) {
-	var policy imagev1alpha1.ImagePolicy
-	name := types.NamespacedName{
-		Name:      ref.Name,
-		Namespace: ns,
+// writeTarget saves the combined changes from the updaters.
+func (r *ImagePolicyArgoCDUpdateReconciler) writeTarget(ctx context.Context, logger logger, target updateTarget, changed bool) error {
+	if !changed {
+		return nil
+	}
+	if err := target.Save(ctx); err != nil {
+		logger.error(err, "failed to update the ArgoCD resource")
+		return err
 	}
-	if err := r.Get(ctx, name, &policy); err != nil {
-		return nil, err
+	return nil
+}
+
+func loggerForUpdater(l logger, updater *appsv1alpha1.ImagePolicyArgoCDUpdate) logger {
+	return newLogger(l.log.WithValues("imagepolicyargocdupdate", types.NamespacedName{Name: updater.Name, Namespace: updater.Namespace}))
+}
+
+// setReadiness records the Ready condition in the updater's status.
+func (r *ImagePolicyArgoCDUpdateReconciler) setReadiness(ctx context.Context, updater *appsv1alpha1.ImagePolicyArgoCDUpdate, status corev1.ConditionStatus, reason, message string) error {
+	appsv1alpha1.SetReadiness(updater, status, reason, message)
+	return r.Status().Update(ctx, updater)
+}
+
+// recordFailure marks the updater as not ready because of the error.
+//
+// Failing to update the status is logged, the original error is more useful to
+// return from Reconcile.
+func (r *ImagePolicyArgoCDUpdateReconciler) recordFailure(ctx context.Context, logger logger, updater *appsv1alpha1.ImagePolicyArgoCDUpdate, reason string, err error) {
+	if err := r.setReadiness(ctx, updater, corev1.ConditionFalse, reason, err.Error()); err != nil {
+		logger.error(err, "failed to update the status")
 	}
-	return &policy, nil
 }
 
+func (r *ImagePolicyArgoCDUpdateReconciler) loadImagePolicy(ctx context.Context, c client.Client, ns string, ref corev1.LocalObjectReference) (*imagepolicy.ImagePolicy, error) {
+	return r.imagePolicies.Get(ctx, c, types.NamespacedName{Name: ref.Name, Namespace: ns})
+}
+
+// RequiredResources are the APIs that must be served before the controller is
+// started with SetupController.
+var RequiredResources = []schema.GroupVersionResource{
+	{Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"},
+	// Any of the supported ImagePolicy versions can be used.
+	imagepolicy.Resource,
+}
+
+// SetupWithManager registers the indexes and the controller with the manager.
 func (r *ImagePolicyArgoCDUpdateReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	// Index the ImagePolicy that each ArgoCD Update references
-	if err := mgr.GetFieldIndexer().IndexField(&appsv1alpha1.ImagePolicyArgoCDUpdate{}, imagePolicyKey, func(obj runtime.Object) []string {
-		updater := obj.(*appsv1alpha1.ImagePolicyArgoCDUpdate)
+	if err := r.SetupIndexes(mgr); err != nil {
+		return err
+	}
+	return r.SetupController(mgr)
+}
+
+// SetupIndexes registers the indexes on the updaters, this must be done
+// before the manager is started.
+func (r *ImagePolicyArgoCDUpdateReconciler) SetupIndexes(mgr ctrl.Manager) error {
+	for key, index := range updaterIndexes {
+		index := index
+		if err := mgr.GetFieldIndexer().IndexField(context.Background(), &appsv1alpha1.ImagePolicyArgoCDUpdate{}, key, func(obj runtime.Object) []string {
+			return index(obj.(*appsv1alpha1.ImagePolicyArgoCDUpdate))
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updaterIndexes are the fields that the updaters are indexed by.
+var updaterIndexes = map[string]func(*appsv1alpha1.ImagePolicyArgoCDUpdate) []string{
+	// The update target of each ArgoCD Update, updaters are reconciled
+	// together by target
+	applicationKey: func(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) []string {
+		return []string{updateTargetKey(updater)}
+	},
+
+	// The resource that each ArgoCD Update writes to, whichever credentials
+	// it uses, to find conflicts between updaters that are reconciled
+	// separately
+	targetNameKey: func(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) []string {
+		if name := appsv1alpha1.TargetName(updater); name != "" {
+			return []string{name}
+		}
+		return nil
+	},
+
+	// The ImagePolicy that each ArgoCD Update references
+	imagePolicyKey: func(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) []string {
 		return []string{updater.Spec.ImagePolicyRef.Name}
-	}); err != nil {
+	},
+
+	// The Secrets that each ArgoCD Update uses to access the Application, and
+	// to check and verify images
+	secretsKey: func(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) []string {
+		spec := updater.Spec
+		var names []string
+		if ref := spec.ApplicationRef; ref != nil {
+			if ref.KubeConfig != nil {
+				names = append(names, ref.KubeConfig.SecretRef.Name)
+			}
+			if ref.API != nil {
+				names = append(names, ref.API.SecretRef.Name)
+			}
+		}
+		if spec.Verification != nil && spec.Verification.PublicKeys.SecretRef != nil {
+			names = append(names, spec.Verification.PublicKeys.SecretRef.Name)
+		}
+		if spec.ImageCheck != nil {
+			for _, secret := range spec.ImageCheck.PullSecrets {
+				names = append(names, secret.Name)
+			}
+		}
+		return names
+	},
+
+	// The ConfigMaps that each ArgoCD Update reads public keys from.
+	configMapsKey: func(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) []string {
+		if v := updater.Spec.Verification; v != nil && v.PublicKeys.ConfigMapRef != nil {
+			return []string{v.PublicKeys.ConfigMapRef.Name}
+		}
+		return nil
+	},
+}
+
+// SetupController creates the controller and its watches, this can be called
+// after the manager is started, once the RequiredResources are served.
+func (r *ImagePolicyArgoCDUpdateReconciler) SetupController(mgr ctrl.Manager) error {
+	if r.imagePolicies.Version == "" {
+		d, err := discovery.NewDiscoveryClientForConfig(r.Config)
+		if err != nil {
+			return err
+		}
+		version, err := imagepolicy.ServedVersion(d)
+		if err != nil {
+			return err
+		}
+		r.Log.Info("reading ImagePolicies", "version", version)
+		r.imagePolicies = imagepolicy.Reader{Version: version}
+	}
+	if r.remoteClients == nil {
+		r.remoteClients = remote.NewClientCache(func(cfg *rest.Config) (client.Client, error) {
+			return client.New(cfg, client.Options{Scheme: r.Scheme})
+		})
+	}
+	if r.now == nil {
+		r.now = time.Now
+	}
+	if r.registryClient == nil {
+		r.registryClient = registry.NewClient(&http.Client{Timeout: registryTimeout})
+	}
+
+	// The controller is built directly, rather than with For(), because the
+	// requests are for update targets, not updaters.
+	c, err := controller.New("imagepolicyargocdupdate", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+		RateLimiter:             r.RateLimiter,
+	})
+	if err != nil {
 		return err
 	}
+	if err := c.Watch(&source.Kind{Type: &appsv1alpha1.ImagePolicyArgoCDUpdate{}},
+		&handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.targetsForAutomation),
+		}); err != nil {
+		return err
+	}
+	if err := c.Watch(&source.Kind{Type: r.imagePolicies.NewObject()},
+		&handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.automationsForImagePolicy),
+		}); err != nil {
+		return err
+	}
+	if err := c.Watch(&source.Kind{Type: &corev1.Secret{}},
+		&handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.automationsForSecret),
+		}); err != nil {
+		return err
+	}
+	return c.Watch(&source.Kind{Type: &corev1.ConfigMap{}},
+		&handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.automationsForConfigMap),
+		})
+}
 
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&appsv1alpha1.ImagePolicyArgoCDUpdate{}).
-		Watches(&source.Kind{Type: &imagev1alpha1.ImagePolicy{}},
-			&handler.EnqueueRequestsFromMapFunc{
-				ToRequests: handler.ToRequestsFunc(r.automationsForImagePolicy),
-			}).
-		Complete(r)
+// targetsForAutomation returns the update target of an automation.
+//
+// The updaters that write to the same resource with other credentials are
+// reconciled too, so that they find any conflicts with the automation.
+func (r *ImagePolicyArgoCDUpdateReconciler) targetsForAutomation(obj handler.MapObject) []ctrl.Request {
+	updater, ok := obj.Object.(*appsv1alpha1.ImagePolicyArgoCDUpdate)
+	if !ok {
+		return nil
+	}
+	items := []appsv1alpha1.ImagePolicyArgoCDUpdate{*updater}
+	if name := appsv1alpha1.TargetName(updater); name != "" {
+		var autoList appsv1alpha1.ImagePolicyArgoCDUpdateList
+		if err := r.List(context.Background(), &autoList, client.MatchingFields{targetNameKey: name}); err != nil {
+			r.Log.Error(err, "failed to list ImageUpdateAutomations for the target", "target", name)
+		} else {
+			items = append(items, autoList.Items...)
+		}
+	}
+	return requestsForAutomations(items)
 }
 
 // automationsForImagePolicy fetches all the automations that refer to
@@ -144,10 +481,51 @@ func (r *ImagePolicyArgoCDUpdateReconciler) automationsForImagePolicy(obj handle
 		})
 		return nil
 	}
-	reqs := make([]ctrl.Request, len(autoList.Items), len(autoList.Items))
-	for i := range autoList.Items {
-		reqs[i].NamespacedName.Name = autoList.Items[i].GetName()
-		reqs[i].NamespacedName.Namespace = autoList.Items[i].GetNamespace()
+	return requestsForAutomations(autoList.Items)
+}
+
+// automationsForSecret fetches all the automations that use a particular
+// Secret to access their Application.
+func (r *ImagePolicyArgoCDUpdateReconciler) automationsForSecret(obj handler.MapObject) []ctrl.Request {
+	ctx := context.Background()
+	var autoList appsv1alpha1.ImagePolicyArgoCDUpdateList
+	if err := r.List(ctx, &autoList, client.InNamespace(obj.Meta.GetNamespace()), client.MatchingFields{secretsKey: obj.Meta.GetName()}); err != nil {
+		r.Log.Error(err, "failed to list ImageUpdateAutomations for Secret", "name", types.NamespacedName{
+			Name:      obj.Meta.GetName(),
+			Namespace: obj.Meta.GetNamespace(),
+		})
+		return nil
+	}
+	return requestsForAutomations(autoList.Items)
+}
+
+// automationsForConfigMap fetches all the automations that read public keys
+// from a particular ConfigMap.
+func (r *ImagePolicyArgoCDUpdateReconciler) automationsForConfigMap(obj handler.MapObject) []ctrl.Request {
+	ctx := context.Background()
+	var autoList appsv1alpha1.ImagePolicyArgoCDUpdateList
+	if err := r.List(ctx, &autoList, client.InNamespace(obj.Meta.GetNamespace()), client.MatchingFields{configMapsKey: obj.Meta.GetName()}); err != nil {
+		r.Log.Error(err, "failed to list ImageUpdateAutomations for ConfigMap", "name", types.NamespacedName{
+			Name:      obj.Meta.GetName(),
+			Namespace: obj.Meta.GetNamespace(),
+		})
+		return nil
+	}
+	return requestsForAutomations(autoList.Items)
+}
+
+// requestsForAutomations returns a request for each of the update targets of
+// the automations.
+func requestsForAutomations(items []appsv1alpha1.ImagePolicyArgoCDUpdate) []ctrl.Request {
+	seen := map[string]bool{}
+	reqs := []ctrl.Request{}
+	for i := range items {
+		key := updateTargetKey(&items[i])
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		reqs = append(reqs, ctrl.Request{NamespacedName: types.NamespacedName{Name: key}})
 	}
 	return reqs
 }
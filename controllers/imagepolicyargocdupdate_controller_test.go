@@ -0,0 +1,350 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/imagepolicy"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/remote"
+)
+
+var testPolicies = imagepolicy.Reader{Version: "v1beta2"}
+
+func TestReconcileCombinesUpdatersForAnApplication(t *testing.T) {
+	c := newApplicationsClient(t,
+		makeTestUpdater("go-demo-updater", "go-demo"),
+		makeTestUpdater("other-updater", "other"),
+		makeTestPolicy("go-demo", "bigkevmcd/go-demo", "v1.1.0"),
+		makeTestPolicy("other", "bigkevmcd/other", "v2.0.0"),
+		makeTestApplication("bigkevmcd/go-demo:v1.0.0", "bigkevmcd/other:v1.0.0"),
+	)
+	r := newTestReconciler(c)
+
+	reconcileTarget(t, r, makeTestUpdater("go-demo-updater", "go-demo"))
+
+	if c.updates != 1 {
+		t.Fatalf("got %d updates to the Application, want 1", c.updates)
+	}
+	assertApplicationImages(t, c, "bigkevmcd/go-demo:v1.1.0", "bigkevmcd/other:v2.0.0")
+	assertReady(t, c, "go-demo-updater", corev1.ConditionTrue, appsv1alpha1.ReconciliationSucceededReason, "applied image bigkevmcd/go-demo:v1.1.0")
+	assertReady(t, c, "other-updater", corev1.ConditionTrue, appsv1alpha1.ReconciliationSucceededReason, "applied image bigkevmcd/other:v2.0.0")
+}
+
+func TestReconcileRecordsWriteFailuresOnEveryUpdater(t *testing.T) {
+	c := newApplicationsClient(t,
+		makeTestUpdater("go-demo-updater", "go-demo"),
+		makeTestUpdater("other-updater", "other"),
+		makeTestPolicy("go-demo", "bigkevmcd/go-demo", "v1.1.0"),
+		makeTestPolicy("other", "bigkevmcd/other", "v2.0.0"),
+		makeTestApplication("bigkevmcd/go-demo:v1.0.0", "bigkevmcd/other:v1.0.0"),
+	)
+	c.updateErr = errors.New("the Application can't be written")
+	r := newTestReconciler(c)
+
+	result := reconcileTarget(t, r, makeTestUpdater("go-demo-updater", "go-demo"))
+
+	if result.RequeueAfter == 0 {
+		t.Fatal("the failed write was not retried")
+	}
+	for _, name := range []string{"go-demo-updater", "other-updater"} {
+		updater := assertReady(t, c, name, corev1.ConditionFalse, appsv1alpha1.ReconciliationFailedReason, "the Application can't be written")
+		if updater.Status.Retry == nil || updater.Status.Retry.Failures != 1 {
+			t.Errorf("%s: got retry status %#v, want 1 failure", name, updater.Status.Retry)
+		}
+		if updater.Status.LastUpdateTime != nil {
+			t.Errorf("%s: the update was recorded, but the Application was not written", name)
+		}
+	}
+	assertApplicationImages(t, c, "bigkevmcd/go-demo:v1.0.0", "bigkevmcd/other:v1.0.0")
+}
+
+func TestReconcileWaitsForTheLatestImage(t *testing.T) {
+	policy := makeTestPolicy("other", "bigkevmcd/other", "v2.0.0")
+	delete(policy.Object, "status")
+	c := newApplicationsClient(t,
+		makeTestUpdater("go-demo-updater", "go-demo"),
+		makeTestUpdater("other-updater", "other"),
+		makeTestPolicy("go-demo", "bigkevmcd/go-demo", "v1.1.0"),
+		policy,
+		makeTestApplication("bigkevmcd/go-demo:v1.0.0", "bigkevmcd/other:v1.0.0"),
+	)
+	r := newTestReconciler(c)
+
+	result := reconcileTarget(t, r, makeTestUpdater("go-demo-updater", "go-demo"))
+
+	if result.RequeueAfter != 0 {
+		t.Fatalf("got RequeueAfter %s, want the ImagePolicy to trigger the reconciliation", result.RequeueAfter)
+	}
+	// Updated images are moved to the end of the overrides.
+	assertApplicationImages(t, c, "bigkevmcd/other:v1.0.0", "bigkevmcd/go-demo:v1.1.0")
+	assertReady(t, c, "go-demo-updater", corev1.ConditionTrue, appsv1alpha1.ReconciliationSucceededReason, "applied image bigkevmcd/go-demo:v1.1.0")
+	updater := assertReady(t, c, "other-updater", corev1.ConditionFalse, appsv1alpha1.NoLatestImageReason, "the ImagePolicy other has no latest image yet")
+	if updater.Status.Retry != nil {
+		t.Fatalf("got retry status %#v, want no retries", updater.Status.Retry)
+	}
+}
+
+func TestReconcileAppliesTheMinIntervalToTheApplication(t *testing.T) {
+	deferred := makeTestUpdater("go-demo-updater", "go-demo")
+	deferred.Spec.MinInterval = &metav1.Duration{Duration: time.Hour}
+	other := makeTestUpdater("other-updater", "other")
+	updated := metav1.NewTime(time.Date(2020, time.August, 19, 11, 30, 0, 0, time.UTC))
+	other.Status.LastUpdateTime = &updated
+	c := newApplicationsClient(t,
+		deferred,
+		other,
+		makeTestPolicy("go-demo", "bigkevmcd/go-demo", "v1.1.0"),
+		makeTestPolicy("other", "bigkevmcd/other", "v1.0.0"),
+		makeTestApplication("bigkevmcd/go-demo:v1.0.0", "bigkevmcd/other:v1.0.0"),
+	)
+	r := newTestReconciler(c)
+
+	result := reconcileTarget(t, r, makeTestUpdater("go-demo-updater", "go-demo"))
+
+	if result.RequeueAfter != 30*time.Minute {
+		t.Fatalf("got RequeueAfter %s, want %s", result.RequeueAfter, 30*time.Minute)
+	}
+	if c.updates != 0 {
+		t.Fatalf("got %d updates to the Application, want 0", c.updates)
+	}
+	updater := assertReady(t, c, "go-demo-updater", corev1.ConditionFalse, appsv1alpha1.UpdateDeferredReason,
+		"image bigkevmcd/go-demo:v1.1.0 will be applied after 2020-08-19T12:30:00Z")
+	if updater.Status.CandidateImage != "bigkevmcd/go-demo:v1.1.0" {
+		t.Fatalf("got candidate image %q, want %q", updater.Status.CandidateImage, "bigkevmcd/go-demo:v1.1.0")
+	}
+}
+
+func TestReconcileSkipsSuspendedUpdaters(t *testing.T) {
+	suspended := makeTestUpdater("other-updater", "other")
+	suspended.Spec.Suspend = true
+	c := newApplicationsClient(t,
+		makeTestUpdater("go-demo-updater", "go-demo"),
+		suspended,
+		makeTestPolicy("go-demo", "bigkevmcd/go-demo", "v1.1.0"),
+		makeTestPolicy("other", "bigkevmcd/other", "v2.0.0"),
+		makeTestApplication("bigkevmcd/go-demo:v1.0.0", "bigkevmcd/other:v1.0.0"),
+	)
+	r := newTestReconciler(c)
+
+	reconcileTarget(t, r, makeTestUpdater("go-demo-updater", "go-demo"))
+
+	if c.updates != 1 {
+		t.Fatalf("got %d updates to the Application, want 1", c.updates)
+	}
+	// Updated images are moved to the end of the overrides.
+	assertApplicationImages(t, c, "bigkevmcd/other:v1.0.0", "bigkevmcd/go-demo:v1.1.0")
+	assertReady(t, c, "go-demo-updater", corev1.ConditionTrue, appsv1alpha1.ReconciliationSucceededReason, "applied image bigkevmcd/go-demo:v1.1.0")
+	assertReady(t, c, "other-updater", corev1.ConditionFalse, appsv1alpha1.SuspendedReason, "the updater is suspended")
+}
+
+func TestAutomationsForConfigMap(t *testing.T) {
+	verified := makeTestUpdater("go-demo-updater", "go-demo")
+	verified.Spec.Verification = &appsv1alpha1.VerificationSpec{
+		PublicKeys: appsv1alpha1.PublicKeysReference{ConfigMapRef: &corev1.LocalObjectReference{Name: "cosign-keys"}},
+	}
+	c := newApplicationsClient(t, verified, makeTestUpdater("other-updater", "other"))
+	r := newTestReconciler(c)
+	keys := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cosign-keys", Namespace: updaterNamespace}}
+
+	reqs := r.automationsForConfigMap(handler.MapObject{Meta: keys, Object: keys})
+
+	want := []ctrl.Request{{NamespacedName: types.NamespacedName{Name: updateTargetKey(verified)}}}
+	if diff := cmp.Diff(want, reqs); diff != "" {
+		t.Fatalf("requests failed diff -want +got:\n%s", diff)
+	}
+}
+
+// applicationsClient records the updates to Applications, and can fail them.
+type applicationsClient struct {
+	client.Client
+	updates   int
+	updateErr error
+	getErr    error
+}
+
+func (c *applicationsClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok && u.GetKind() == application.GroupVersionKind.Kind && c.getErr != nil {
+		return c.getErr
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *applicationsClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok && u.GetKind() == application.GroupVersionKind.Kind {
+		c.updates++
+		if c.updateErr != nil {
+			return c.updateErr
+		}
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func newApplicationsClient(t *testing.T, objs ...runtime.Object) *applicationsClient {
+	t.Helper()
+	return &applicationsClient{Client: indexedClient{fake.NewFakeClientWithScheme(newTestScheme(t), objs...)}}
+}
+
+// indexedClient filters lists of updaters by the updaterIndexes, as the
+// manager's cache does, the fake client ignores field selectors.
+type indexedClient struct {
+	client.Client
+}
+
+func (c indexedClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	if err := c.Client.List(ctx, list, opts...); err != nil {
+		return err
+	}
+	updaters, ok := list.(*appsv1alpha1.ImagePolicyArgoCDUpdateList)
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if !ok || listOpts.FieldSelector == nil {
+		return nil
+	}
+	var items []appsv1alpha1.ImagePolicyArgoCDUpdate
+	for _, item := range updaters.Items {
+		matches := true
+		for _, req := range listOpts.FieldSelector.Requirements() {
+			matches = matches && containsString(updaterIndexes[req.Field](&item), req.Value)
+		}
+		if matches {
+			items = append(items, item)
+		}
+	}
+	updaters.Items = items
+	return nil
+}
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	// The fake client decodes lists with the client-go scheme.
+	if err := appsv1alpha1.AddToScheme(clientgoscheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+	s := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, appsv1alpha1.AddToScheme} {
+		if err := add(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// newTestReconciler returns a reconciler that uses the client for everything,
+// including impersonating ServiceAccounts.
+func newTestReconciler(c client.Client) *ImagePolicyArgoCDUpdateReconciler {
+	return &ImagePolicyArgoCDUpdateReconciler{
+		Client:        c,
+		Log:           logf.NullLogger{},
+		Config:        &rest.Config{Host: "https://kubernetes.default.svc"},
+		imagePolicies: testPolicies,
+		remoteClients: remote.NewClientCache(func(*rest.Config) (client.Client, error) { return c, nil }),
+		now:           func() time.Time { return time.Date(2020, time.August, 19, 12, 0, 0, 0, time.UTC) },
+		newClient:     func(*rest.Config) (client.Client, error) { return c, nil },
+	}
+}
+
+func reconcileTarget(t *testing.T, r *ImagePolicyArgoCDUpdateReconciler, updater *appsv1alpha1.ImagePolicyArgoCDUpdate) ctrl.Result {
+	t.Helper()
+	result, err := r.Reconcile(reconcileRequest(updater))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// reconcileRequest returns the request for the updater's target.
+func reconcileRequest(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) ctrl.Request {
+	return ctrl.Request{NamespacedName: types.NamespacedName{Name: updateTargetKey(updater)}}
+}
+
+func makeTestUpdater(name, policy string) *appsv1alpha1.ImagePolicyArgoCDUpdate {
+	return &appsv1alpha1.ImagePolicyArgoCDUpdate{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: updaterNamespace},
+		Spec: appsv1alpha1.ImagePolicyArgoCDUpdateSpec{
+			ApplicationRef: &appsv1alpha1.ApplicationReference{Name: argoAppName, Namespace: argoAppNamespace},
+			ImagePolicyRef: corev1.LocalObjectReference{Name: policy},
+		},
+	}
+}
+
+func makeTestPolicy(name, image, tag string) *unstructured.Unstructured {
+	policy := testPolicies.NewObject()
+	policy.SetName(name)
+	policy.SetNamespace(updaterNamespace)
+	policy.Object["status"] = map[string]interface{}{
+		"latestRef": map[string]interface{}{"name": image, "tag": tag},
+	}
+	return policy
+}
+
+func makeTestApplication(images ...string) *unstructured.Unstructured {
+	app := application.NewObject()
+	app.SetName(argoAppName)
+	app.SetNamespace(argoAppNamespace)
+	kustomizeImages := []interface{}{}
+	for _, image := range images {
+		kustomizeImages = append(kustomizeImages, image)
+	}
+	app.Object["spec"] = map[string]interface{}{
+		"source": map[string]interface{}{
+			"repoURL":   "https://github.com/bigkevmcd/go-demo.git",
+			"path":      "examples/kustomize/overlays/dev",
+			"kustomize": map[string]interface{}{"images": kustomizeImages},
+		},
+	}
+	return app
+}
+
+func assertApplicationImages(t *testing.T, c client.Client, want ...string) {
+	t.Helper()
+	obj := application.NewObject()
+	if err := c.Get(context.Background(), types.NamespacedName{Name: argoAppName, Namespace: argoAppNamespace}, obj); err != nil {
+		t.Fatal(err)
+	}
+	app, err := application.New(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var images []string
+	for _, image := range app.Spec.Source.Kustomize.Images {
+		images = append(images, string(image))
+	}
+	if diff := cmp.Diff(want, images); diff != "" {
+		t.Fatalf("the Application images don't match:\n%s", diff)
+	}
+}
+
+func assertReady(t *testing.T, c client.Client, name string, status corev1.ConditionStatus, reason, message string) *appsv1alpha1.ImagePolicyArgoCDUpdate {
+	t.Helper()
+	var updater appsv1alpha1.ImagePolicyArgoCDUpdate
+	if err := c.Get(context.Background(), types.NamespacedName{Name: name, Namespace: updaterNamespace}, &updater); err != nil {
+		t.Fatal(err)
+	}
+	ready := appsv1alpha1.FindCondition(updater.Status.Conditions, appsv1alpha1.ReadyCondition)
+	if ready == nil {
+		t.Fatalf("%s has no Ready condition", name)
+	}
+	want := []string{string(status), reason, message}
+	if diff := cmp.Diff(want, []string{string(ready.Status), ready.Reason, ready.Message}); diff != "" {
+		t.Fatalf("%s has the wrong Ready condition:\n%s", name, diff)
+	}
+	return &updater
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+)
+
+// clientFor returns a client that impersonates the ServiceAccount named in the
+// updater, or the reconciler's own client if no ServiceAccount is named.
+//
+// The impersonating client is not backed by the manager's cache, all reads go
+// to the API server, so that the ServiceAccount's RBAC applies to them too.
+func (r *ImagePolicyArgoCDUpdateReconciler) clientFor(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) (client.Client, error) {
+	if updater.Spec.ServiceAccountName == "" {
+		return r.Client, nil
+	}
+	cfg := rest.CopyConfig(r.Config)
+	cfg.Impersonate = rest.ImpersonationConfig{
+		UserName: serviceAccountUsername(updater.Namespace, updater.Spec.ServiceAccountName),
+	}
+	if r.newClient != nil {
+		return r.newClient(cfg)
+	}
+	return client.New(cfg, client.Options{Scheme: r.Scheme, Mapper: r.Mapper})
+}
+
+// serviceAccountUsername returns the username that the API server
+// authenticates a ServiceAccount as.
+func serviceAccountUsername(ns, name string) string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", ns, name)
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+)
+
+func TestClientForImpersonatesTheServiceAccount(t *testing.T) {
+	c := newApplicationsClient(t)
+	r := newTestReconciler(c)
+	r.Config = &rest.Config{Host: "https://kubernetes.default.svc", BearerToken: "controller-token"}
+	var configs []*rest.Config
+	r.newClient = func(cfg *rest.Config) (client.Client, error) {
+		configs = append(configs, cfg)
+		return c, nil
+	}
+	updater := makeTestUpdater("test-updater", "go-demo")
+	updater.Namespace = "team-a"
+	updater.Spec.ServiceAccountName = "deployer"
+
+	if _, err := r.clientFor(updater); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*rest.Config{{
+		Host:        "https://kubernetes.default.svc",
+		BearerToken: "controller-token",
+		Impersonate: rest.ImpersonationConfig{UserName: "system:serviceaccount:team-a:deployer"},
+	}}
+	if diff := cmp.Diff(want, configs); diff != "" {
+		t.Fatalf("got the wrong client configuration:\n%s", diff)
+	}
+	if r.Config.Impersonate.UserName != "" {
+		t.Fatalf("the reconciler's configuration impersonates %q", r.Config.Impersonate.UserName)
+	}
+}
+
+func TestClientForWithoutAServiceAccount(t *testing.T) {
+	c := newApplicationsClient(t)
+	r := newTestReconciler(c)
+	r.newClient = func(cfg *rest.Config) (client.Client, error) {
+		t.Fatalf("created a client impersonating %q", cfg.Impersonate.UserName)
+		return nil, nil
+	}
+
+	got, err := r.clientFor(makeTestUpdater("test-updater", "go-demo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != r.Client {
+		t.Fatal("got a new client, want the reconciler's client")
+	}
+}
+
+func TestReconcileImpersonatesTheServiceAccount(t *testing.T) {
+	updater := makeTestUpdater("test-updater", "go-demo")
+	updater.Spec.ServiceAccountName = "deployer"
+	c := newApplicationsClient(t, updater,
+		makeTestPolicy("go-demo", "bigkevmcd/go-demo", "v1.1.0"),
+		makeTestApplication("bigkevmcd/go-demo:v1.0.0"))
+	r := newTestReconciler(c)
+	var usernames []string
+	r.newClient = func(cfg *rest.Config) (client.Client, error) {
+		usernames = append(usernames, cfg.Impersonate.UserName)
+		return c, nil
+	}
+
+	reconcileTarget(t, r, updater)
+
+	if len(usernames) == 0 {
+		t.Fatal("the ServiceAccount was not impersonated")
+	}
+	for _, username := range usernames {
+		if username != "system:serviceaccount:updaters:deployer" {
+			t.Fatalf("impersonated %q, want the updater's ServiceAccount", username)
+		}
+	}
+	assertApplicationImages(t, c, "bigkevmcd/go-demo:v1.1.0")
+}
+
+func TestTargetIdentityImpersonatesTheServiceAccount(t *testing.T) {
+	updater := makeTestUpdater("test-updater", "go-demo")
+	updater.Spec.ServiceAccountName = "deployer"
+	updater.Spec.ApplicationRef.KubeConfig = &appsv1alpha1.KubeConfigReference{SecretRef: corev1.LocalObjectReference{Name: "remote-cluster"}}
+	// Only the ServiceAccount can read the Secret.
+	impersonated := newApplicationsClient(t, makeKubeConfigSecret("remote-cluster", "https://remote.example.com"))
+	r := newTestReconciler(newApplicationsClient(t))
+	var usernames []string
+	r.newClient = func(cfg *rest.Config) (client.Client, error) {
+		usernames = append(usernames, cfg.Impersonate.UserName)
+		return impersonated, nil
+	}
+
+	identity, err := r.targetIdentity(context.Background(), updater)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "cluster=https://remote.example.com," + appsv1alpha1.TargetName(updater); identity != want {
+		t.Fatalf("got identity %q, want %q", identity, want)
+	}
+	if diff := cmp.Diff([]string{"system:serviceaccount:updaters:deployer"}, usernames); diff != "" {
+		t.Fatalf("got the wrong impersonated users:\n%s", diff)
+	}
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"fmt"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+)
+
+// watchesNamespace returns true if the updaters in the namespace are
+// reconciled.
+func (r *ImagePolicyArgoCDUpdateReconciler) watchesNamespace(ns string) bool {
+	return namespaceAllowed(r.Namespaces, ns)
+}
+
+// checkTargetNamespace returns an error if the updater's target is in the
+// controller's cluster, in a namespace that can't be updated.
+//
+// Targets accessed through a kubeconfig or the ArgoCD API use their own
+// credentials, and are not restricted.
+func (r *ImagePolicyArgoCDUpdateReconciler) checkTargetNamespace(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) error {
+	var kind, ns string
+	switch spec := updater.Spec; {
+	case spec.ApplicationSetRef != nil:
+		kind, ns = "ApplicationSets", spec.ApplicationSetRef.Namespace
+	case spec.ApplicationRef != nil && spec.ApplicationRef.KubeConfig == nil && spec.ApplicationRef.API == nil:
+		kind, ns = "Applications", spec.ApplicationRef.Namespace
+	default:
+		return nil
+	}
+	if !namespaceAllowed(r.ApplicationNamespaces, ns) {
+		return withReason(appsv1alpha1.InvalidSpecReason, fmt.Errorf("%s in namespace %q can't be updated, the allowed namespaces are %v", kind, ns, r.ApplicationNamespaces))
+	}
+	return nil
+}
+
+// namespaceAllowed returns true if the namespace is in the allowed namespaces,
+// or if there are no allowed namespaces, which allows all namespaces.
+func namespaceAllowed(allowed []string, ns string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, v := range allowed {
+		if v == ns {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+)
+
+func TestCheckTargetNamespace(t *testing.T) {
+	kubeConfig := &appsv1alpha1.KubeConfigReference{SecretRef: corev1.LocalObjectReference{Name: "remote-cluster"}}
+	api := &appsv1alpha1.ArgoCDAPIReference{SecretRef: corev1.LocalObjectReference{Name: "argocd-api"}}
+	namespaceTests := []struct {
+		name    string
+		allowed []string
+		ref     *appsv1alpha1.ApplicationReference
+		setRef  *appsv1alpha1.ApplicationSetReference
+		wantErr string
+	}{
+		{"all namespaces allowed", nil, &appsv1alpha1.ApplicationReference{Name: argoAppName, Namespace: "team-a"}, nil, ""},
+		{"allowed namespace", []string{"argocd", "team-a"}, &appsv1alpha1.ApplicationReference{Name: argoAppName, Namespace: "team-a"}, nil, ""},
+		{"Application outside the namespaces", []string{"argocd"}, &appsv1alpha1.ApplicationReference{Name: argoAppName, Namespace: "team-a"}, nil,
+			`Applications in namespace "team-a" can't be updated, the allowed namespaces are [argocd]`},
+		{"ApplicationSet outside the namespaces", []string{"argocd"}, nil, &appsv1alpha1.ApplicationSetReference{Name: "my-appset", Namespace: "team-a"},
+			`ApplicationSets in namespace "team-a" can't be updated, the allowed namespaces are [argocd]`},
+		{"Application in another cluster", []string{"argocd"}, &appsv1alpha1.ApplicationReference{Name: argoAppName, Namespace: "team-a", KubeConfig: kubeConfig}, nil, ""},
+		{"Application through the API", []string{"argocd"}, &appsv1alpha1.ApplicationReference{Name: argoAppName, Namespace: "team-a", API: api}, nil, ""},
+	}
+
+	for _, tt := range namespaceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ImagePolicyArgoCDUpdateReconciler{ApplicationNamespaces: tt.allowed}
+			updater := makeTestUpdater("test-updater", "go-demo")
+			updater.Spec.ApplicationRef = tt.ref
+			updater.Spec.ApplicationSetRef = tt.setRef
+
+			err := r.checkTargetNamespace(updater)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got error %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+			if reason := reasonOf(err, ""); reason != appsv1alpha1.InvalidSpecReason {
+				t.Fatalf("got reason %q, want %q", reason, appsv1alpha1.InvalidSpecReason)
+			}
+		})
+	}
+}
+
+func TestWatchesNamespace(t *testing.T) {
+	r := &ImagePolicyArgoCDUpdateReconciler{Namespaces: []string{"team-a", "team-b"}}
+
+	if !r.watchesNamespace("team-b") {
+		t.Error("team-b is not watched")
+	}
+	if r.watchesNamespace("team-c") {
+		t.Error("team-c is watched")
+	}
+	if r := (&ImagePolicyArgoCDUpdateReconciler{}); !r.watchesNamespace("team-c") {
+		t.Error("all namespaces are not watched without namespaces")
+	}
+}
+
+func TestReconcileRejectsApplicationsOutsideTheNamespaces(t *testing.T) {
+	updater := makeTestUpdater("test-updater", "go-demo")
+	c := newApplicationsClient(t, updater,
+		makeTestPolicy("go-demo", "bigkevmcd/go-demo", "v1.1.0"),
+		makeTestApplication("bigkevmcd/go-demo:v1.0.0"))
+	r := newTestReconciler(c)
+	r.ApplicationNamespaces = []string{updaterNamespace}
+
+	reconcileTarget(t, r, updater)
+
+	if c.updates != 0 {
+		t.Fatalf("got %d updates to the Application, want 0", c.updates)
+	}
+	assertApplicationImages(t, c, "bigkevmcd/go-demo:v1.0.0")
+	assertReady(t, c, "test-updater", corev1.ConditionFalse, appsv1alpha1.InvalidSpecReason,
+		`Applications in namespace "argocd" can't be updated, the allowed namespaces are [updaters]`)
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"strconv"
+	"time"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/imagepolicy"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/update"
+)
+
+const (
+	// infoUpdatedByName is the name of the spec.info entry that records the
+	// updater and image.
+	infoUpdatedByName = "Image updated by image-policy-argo-updater"
+	// infoURLName is the name of the spec.info entry with the updater's URL.
+	infoURLName = "Image update details"
+)
+
+// recordProvenance annotates the target with the details of the change, and
+// maintains the spec.info entries if the updater enables them.
+//
+// The changes are written when the target is saved.
+func recordProvenance(target updateTarget, updater *appsv1alpha1.ImagePolicyArgoCDUpdate, imagePolicy *imagepolicy.ImagePolicy, previous, image string, now time.Time) error {
+	var entries []application.Info
+	if provenance := updater.Spec.Provenance; provenance != nil && provenance.Info {
+		entries = append(entries, application.Info{
+			Name: infoUpdatedByName, Value: updater.Namespace + "/" + updater.Name + " applied " + image,
+		})
+		if provenance.URL != "" {
+			rendered, err := update.RenderTemplates(map[string]string{"url": provenance.URL}, update.ParseImage(image))
+			if err != nil {
+				return err
+			}
+			entries = append(entries, application.Info{Name: infoURLName, Value: rendered["url"]})
+		}
+	}
+
+	obj := target.Object()
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[appsv1alpha1.UpdatedByAnnotation] = updater.Namespace + "/" + updater.Name
+	annotations[appsv1alpha1.PreviousImageAnnotation] = previous
+	annotations[appsv1alpha1.ImageAnnotation] = image
+	annotations[appsv1alpha1.ImagePolicyGenerationAnnotation] = strconv.FormatInt(imagePolicy.Generation, 10)
+	annotations[appsv1alpha1.UpdatedAtAnnotation] = now.UTC().Format(time.RFC3339)
+	obj.SetAnnotations(annotations)
+
+	if len(entries) > 0 {
+		spec := target.Spec()
+		spec.Info = update.SetInfo(spec.Info, entries...)
+	}
+	return nil
+}
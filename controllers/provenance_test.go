@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/types"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+func TestReconcileMaintainsTheProvenanceInfo(t *testing.T) {
+	updater := makeTestUpdater("test-updater", "go-demo")
+	updater.Spec.Provenance = &appsv1alpha1.ProvenanceSpec{Info: true, URL: "https://example.com/releases/{{ .Tag }}"}
+	app := makeTestApplication("bigkevmcd/go-demo:v1.0.0")
+	spec := app.Object["spec"].(map[string]interface{})
+	spec["info"] = []interface{}{
+		map[string]interface{}{"name": infoUpdatedByName, "value": "updaters/test-updater applied bigkevmcd/go-demo:v1.0.0"},
+		map[string]interface{}{"name": "owner", "value": "team-a"},
+	}
+	c := newApplicationsClient(t, updater, app, makeTestPolicy("go-demo", "bigkevmcd/go-demo", "v1.1.0"))
+
+	reconcileTarget(t, newTestReconciler(c), updater)
+
+	want := []application.Info{
+		{Name: infoUpdatedByName, Value: "updaters/test-updater applied bigkevmcd/go-demo:v1.1.0"},
+		{Name: "owner", Value: "team-a"},
+		{Name: infoURLName, Value: "https://example.com/releases/v1.1.0"},
+	}
+	if diff := cmp.Diff(want, applicationInfo(t, c)); diff != "" {
+		t.Fatalf("the Application info doesn't match:\n%s", diff)
+	}
+}
+
+func TestReconcileWithoutProvenanceInfo(t *testing.T) {
+	updater := makeTestUpdater("test-updater", "go-demo")
+	app := makeTestApplication("bigkevmcd/go-demo:v1.0.0")
+	app.Object["spec"].(map[string]interface{})["info"] = []interface{}{
+		map[string]interface{}{"name": "owner", "value": "team-a"},
+	}
+	c := newApplicationsClient(t, updater, app, makeTestPolicy("go-demo", "bigkevmcd/go-demo", "v1.1.0"))
+
+	reconcileTarget(t, newTestReconciler(c), updater)
+
+	assertApplicationImages(t, c, "bigkevmcd/go-demo:v1.1.0")
+	if diff := cmp.Diff([]application.Info{{Name: "owner", Value: "team-a"}}, applicationInfo(t, c)); diff != "" {
+		t.Fatalf("the Application info doesn't match:\n%s", diff)
+	}
+}
+
+func applicationInfo(t *testing.T, c *applicationsClient) []application.Info {
+	t.Helper()
+	obj := application.NewObject()
+	if err := c.Get(context.Background(), types.NamespacedName{Name: argoAppName, Namespace: argoAppNamespace}, obj); err != nil {
+		t.Fatal(err)
+	}
+	app, err := application.New(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app.Spec.Info
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/timing"
+)
+
+// classifyError returns the class of the error, which determines how it is
+// retried.
+func classifyError(err error) appsv1alpha1.ErrorClass {
+	switch apiStatusReason(err) {
+	case metav1.StatusReasonForbidden, metav1.StatusReasonUnauthorized:
+		return appsv1alpha1.PermissionError
+	case metav1.StatusReasonNotFound:
+		return appsv1alpha1.ConfigurationError
+	}
+	switch reasonOf(err, "") {
+	case appsv1alpha1.InvalidSpecReason, appsv1alpha1.TemplateErrorReason,
+		appsv1alpha1.KubeConfigErrorReason, appsv1alpha1.PublicKeysErrorReason:
+		return appsv1alpha1.ConfigurationError
+	}
+	return appsv1alpha1.TransientError
+}
+
+// apiStatusReason returns the reason of a Kubernetes API error, which can be
+// wrapped, unlike the apierrors helpers.
+func apiStatusReason(err error) metav1.StatusReason {
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		return status.Status().Reason
+	}
+	return metav1.StatusReasonUnknown
+}
+
+// failureReason returns the reason to record for an error that has no reason
+// of its own.
+func failureReason(err error) string {
+	switch classifyError(err) {
+	case appsv1alpha1.PermissionError:
+		return appsv1alpha1.PermissionDeniedReason
+	}
+	return appsv1alpha1.ReconciliationFailedReason
+}
+
+// scheduleRetry records the failure in the updater's status, and sets when the
+// updater is retried, according to its retry policy and the class of the
+// error.
+func scheduleRetry(res *updaterResult, now time.Time) {
+	updater := res.updater
+	policy := updater.Spec.Retry
+	class := classifyError(res.err)
+	failures := int32(1)
+	if updater.Status.Retry != nil {
+		failures = updater.Status.Retry.Failures + 1
+	}
+	delay := policy.GetMaxBackoff()
+	if class == appsv1alpha1.TransientError {
+		delay = timing.Backoff{Interval: policy.GetInterval(), Max: policy.GetMaxBackoff()}.Delay(int(failures))
+	}
+	updater.Status.Retry = &appsv1alpha1.RetryStatus{
+		Class:         class,
+		Failures:      failures,
+		NextRetryTime: metav1.NewTime(now.Add(delay)),
+	}
+	if res.reason == "" {
+		res.fail(failureReason(res.err), res.err)
+	}
+	res.requeueAfter = delay
+}
+
+// clearRetry removes the failures from the updater's status.
+func clearRetry(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) {
+	updater.Status.Retry = nil
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+)
+
+func TestClassifyError(t *testing.T) {
+	secrets := schema.GroupResource{Resource: "secrets"}
+	classifyTests := []struct {
+		name string
+		err  error
+		want appsv1alpha1.ErrorClass
+	}{
+		{"forbidden", apierrors.NewForbidden(secrets, "test", errors.New("denied")), appsv1alpha1.PermissionError},
+		{"wrapped unauthorized", fmt.Errorf("failed: %w", apierrors.NewUnauthorized("bad token")), appsv1alpha1.PermissionError},
+		{"not found", apierrors.NewNotFound(secrets, "test"), appsv1alpha1.ConfigurationError},
+		{"invalid spec", withReason(appsv1alpha1.InvalidSpecReason, errors.New("invalid")), appsv1alpha1.ConfigurationError},
+		{"kubeconfig", withReason(appsv1alpha1.KubeConfigErrorReason, errors.New("invalid")), appsv1alpha1.ConfigurationError},
+		{"registry", withReason(appsv1alpha1.RegistryErrorReason, errors.New("timeout")), appsv1alpha1.TransientError},
+		{"unavailable", apierrors.NewServiceUnavailable("unavailable"), appsv1alpha1.TransientError},
+	}
+
+	for _, tt := range classifyTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Fatalf("got class %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleRetry(t *testing.T) {
+	now := time.Date(2020, time.August, 19, 12, 0, 0, 0, time.UTC)
+	transient := errors.New("connection refused")
+	retryTests := []struct {
+		name      string
+		retry     *appsv1alpha1.RetryPolicy
+		failures  int32
+		err       error
+		wantDelay time.Duration
+	}{
+		{"first transient failure", nil, 0, transient, 10 * time.Second},
+		{"third transient failure", nil, 2, transient, 40 * time.Second},
+		{"backoff limited", nil, 10, transient, 5 * time.Minute},
+		{"configured interval", retryPolicy(time.Second, time.Minute), 1, transient, 2 * time.Second},
+		{"configured backoff", retryPolicy(time.Second, time.Minute), 10, transient, time.Minute},
+		{"zero interval", retryPolicy(0, 0), 0, transient, 10 * time.Second},
+		{"negative interval", retryPolicy(-time.Second, -time.Second), 10, transient, 5 * time.Minute},
+		{"configuration error", retryPolicy(time.Second, time.Minute), 0, withReason(appsv1alpha1.InvalidSpecReason, errors.New("invalid")), time.Minute},
+		{"zero backoff configuration error", retryPolicy(0, 0), 0, withReason(appsv1alpha1.InvalidSpecReason, errors.New("invalid")), 5 * time.Minute},
+	}
+
+	for _, tt := range retryTests {
+		t.Run(tt.name, func(t *testing.T) {
+			updater := makeTestUpdater("test-updater", "go-demo")
+			updater.Spec.Retry = tt.retry
+			if tt.failures > 0 {
+				updater.Status.Retry = &appsv1alpha1.RetryStatus{Failures: tt.failures}
+			}
+			res := &updaterResult{updater: updater, err: tt.err}
+
+			scheduleRetry(res, now)
+
+			if res.requeueAfter != tt.wantDelay {
+				t.Fatalf("got RequeueAfter %s, want %s", res.requeueAfter, tt.wantDelay)
+			}
+			retry := updater.Status.Retry
+			if retry.Failures != tt.failures+1 || !retry.NextRetryTime.Time.Equal(now.Add(tt.wantDelay)) {
+				t.Fatalf("got %d failures retried at %s, want %d at %s", retry.Failures, retry.NextRetryTime, tt.failures+1, now.Add(tt.wantDelay))
+			}
+			if res.status != corev1.ConditionFalse || res.message != tt.err.Error() {
+				t.Fatalf("got result %s %q, want False %q", res.status, res.message, tt.err)
+			}
+		})
+	}
+}
+
+func retryPolicy(interval, maxBackoff time.Duration) *appsv1alpha1.RetryPolicy {
+	return &appsv1alpha1.RetryPolicy{
+		Interval:   &metav1.Duration{Duration: interval},
+		MaxBackoff: &metav1.Duration{Duration: maxBackoff},
+	}
+}
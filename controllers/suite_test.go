@@ -25,6 +25,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 
 	"k8s.io/apimachinery/pkg/runtime"
@@ -40,8 +41,8 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
-	argov1alpha1 "github.com/argoproj/argo-cd/pkg/apis/application/v1alpha1"
 	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
 	imagev1alpha1 "github.com/fluxcd/image-reflector-controller/api/v1alpha1"
 	// +kubebuilder:scaffold:imports
 )
@@ -90,7 +91,7 @@ var _ = BeforeSuite(func(done Done) {
 	Expect(cfg).ToNot(BeNil())
 
 	schemes := []func(*runtime.Scheme) error{
-		appsv1alpha1.AddToScheme, argov1alpha1.AddToScheme, imagev1alpha1.AddToScheme,
+		appsv1alpha1.AddToScheme, imagev1alpha1.AddToScheme,
 	}
 	for _, v := range schemes {
 		err = v(scheme.Scheme)
@@ -108,6 +109,8 @@ var _ = BeforeSuite(func(done Done) {
 		Client: k8sManager.GetClient(),
 		Log:    ctrl.Log.WithName("controllers").WithName("ImagePolicyArgoCDUpdateReconciler"),
 		Scheme: scheme.Scheme,
+		Config: k8sManager.GetConfig(),
+		Mapper: k8sManager.GetRESTMapper(),
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
@@ -131,7 +134,7 @@ var _ = AfterSuite(func() {
 var _ = Describe("ImagePolicyArgoCDUpdate", func() {
 	var (
 		updater     *appsv1alpha1.ImagePolicyArgoCDUpdate
-		argoApp     *argov1alpha1.Application
+		argoApp     *unstructured.Unstructured
 		policy      *imagev1alpha1.ImagePolicy
 		latestImage string
 	)
@@ -143,7 +146,7 @@ var _ = Describe("ImagePolicyArgoCDUpdate", func() {
 				Namespace: updaterNamespace,
 			},
 			Spec: appsv1alpha1.ImagePolicyArgoCDUpdateSpec{
-				ApplicationRef: corev1.ObjectReference{
+				ApplicationRef: &appsv1alpha1.ApplicationReference{
 					Name:      argoAppName,
 					Namespace: argoAppNamespace,
 				},
@@ -154,14 +157,11 @@ var _ = Describe("ImagePolicyArgoCDUpdate", func() {
 		}
 		Expect(k8sClient.Create(context.Background(), updater)).To(Succeed())
 
-		argoApp = &argov1alpha1.Application{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      argoAppName,
-				Namespace: argoAppNamespace,
-			},
-			Spec: argov1alpha1.ApplicationSpec{
-				Source: argov1alpha1.ApplicationSource{},
-			},
+		argoApp = application.NewObject()
+		argoApp.SetName(argoAppName)
+		argoApp.SetNamespace(argoAppNamespace)
+		argoApp.Object["spec"] = map[string]interface{}{
+			"source": map[string]interface{}{},
 		}
 		Expect(k8sClient.Create(context.Background(), argoApp)).To(Succeed())
 
@@ -205,17 +205,33 @@ var _ = Describe("ImagePolicyArgoCDUpdate", func() {
 				latestImage = "1.14.5"
 			})
 			It("triggers the update of the related ArgoCD application", func() {
-				Eventually(func() argov1alpha1.KustomizeImages {
-					loaded := &argov1alpha1.Application{}
+				Eventually(func() application.KustomizeImages {
+					obj := application.NewObject()
 					Expect(k8sClient.Get(context.Background(), types.NamespacedName{
 						Name:      argoAppName,
 						Namespace: argoAppNamespace,
-					}, loaded)).NotTo(HaveOccurred())
+					}, obj)).NotTo(HaveOccurred())
+					loaded, err := application.New(obj)
+					Expect(err).NotTo(HaveOccurred())
 					if loaded.Spec.Source.Kustomize != nil {
 						return loaded.Spec.Source.Kustomize.Images
 					}
-					return argov1alpha1.KustomizeImages{}
-				}, timeout, time.Millisecond*500).Should(Equal(argov1alpha1.KustomizeImages{argov1alpha1.KustomizeImage(latestImage)}))
+					return application.KustomizeImages{}
+				}, timeout, time.Millisecond*500).Should(Equal(application.KustomizeImages{application.KustomizeImage(latestImage)}))
+			})
+
+			It("marks the ImagePolicyArgoCDUpdate as ready", func() {
+				Eventually(func() corev1.ConditionStatus {
+					loaded := &appsv1alpha1.ImagePolicyArgoCDUpdate{}
+					Expect(k8sClient.Get(context.Background(), types.NamespacedName{
+						Name:      updaterName,
+						Namespace: updaterNamespace,
+					}, loaded)).NotTo(HaveOccurred())
+					if c := appsv1alpha1.FindCondition(loaded.Status.Conditions, appsv1alpha1.ReadyCondition); c != nil {
+						return c.Status
+					}
+					return corev1.ConditionUnknown
+				}, timeout, time.Millisecond*500).Should(Equal(corev1.ConditionTrue))
 			})
 		})
 
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+// updateTarget is an ArgoCD resource with one or more ApplicationSources that
+// the image is written to.
+type updateTarget interface {
+	// Spec returns the view of the Application spec, or the ApplicationSet's
+	// template, changes are made in place, and written by Save.
+	Spec() *application.ApplicationSpec
+
+	// Object returns the metadata of the ArgoCD resource, changes to the
+	// annotations are written by Save.
+	Object() metav1.Object
+
+	// Save writes the changes back.
+	Save(ctx context.Context) error
+}
+
+// sourceFor returns the source of the target that the updater writes to.
+func sourceFor(target updateTarget, updater *appsv1alpha1.ImagePolicyArgoCDUpdate) (*application.ApplicationSource, error) {
+	i, err := target.Spec().SelectSource(updater.Spec.Source)
+	if err != nil {
+		return nil, withReason(appsv1alpha1.InvalidSpecReason, err)
+	}
+	return target.Spec().AllSources()[i], nil
+}
+
+// loadTarget loads the Application or ApplicationSet that the updater
+// references.
+func (r *ImagePolicyArgoCDUpdateReconciler) loadTarget(ctx context.Context, c client.Client, updater *appsv1alpha1.ImagePolicyArgoCDUpdate) (updateTarget, error) {
+	spec := updater.Spec
+	if (spec.ApplicationRef == nil) == (spec.ApplicationSetRef == nil) {
+		return nil, withReason(appsv1alpha1.InvalidSpecReason, errors.New("exactly one of applicationRef and applicationSetRef must be provided"))
+	}
+	if spec.ApplicationSetRef != nil {
+		return loadApplicationSet(ctx, c, *spec.ApplicationSetRef)
+	}
+
+	apps, err := r.applicationsFor(ctx, c, updater)
+	if err != nil {
+		return nil, err
+	}
+	app, err := apps.Get(ctx, *spec.ApplicationRef)
+	if err != nil {
+		return nil, err
+	}
+	return &applicationTarget{app: app, store: apps}, nil
+}
+
+// applicationTarget updates the sources of an Application.
+type applicationTarget struct {
+	app   *application.Application
+	store applicationStore
+}
+
+func (t *applicationTarget) Spec() *application.ApplicationSpec {
+	return &t.app.Spec
+}
+
+func (t *applicationTarget) Object() metav1.Object {
+	return t.app.Object
+}
+
+func (t *applicationTarget) Save(ctx context.Context) error {
+	return t.store.Update(ctx, t.app)
+}
+
+// updateTargetKey identifies the target of the updater, and how it is
+// accessed.
+//
+// Updaters with the same key are reconciled together, so that their changes
+// are written to the target at the same time, updaters that access the target
+// with different credentials are reconciled separately, but are still checked
+// for conflicts with each other.
+func updateTargetKey(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) string {
+	spec := updater.Spec
+	var key string
+	switch {
+	case (spec.ApplicationRef == nil) == (spec.ApplicationSetRef == nil):
+		// This is invalid, and is reconciled alone so that it is reported.
+		return "ImagePolicyArgoCDUpdate/" + updater.Namespace + "/" + updater.Name
+	case spec.ApplicationSetRef != nil:
+		key = "ApplicationSet/" + spec.ApplicationSetRef.Namespace + "/" + spec.ApplicationSetRef.Name
+	default:
+		ref := spec.ApplicationRef
+		key = "Application/" + ref.Namespace + "/" + ref.Name
+		if ref.KubeConfig != nil {
+			key += ",kubeConfig=" + updater.Namespace + "/" + ref.KubeConfig.SecretRef.Name + ":" + ref.KubeConfig.SecretKey()
+		}
+		if ref.API != nil {
+			key += ",api=" + updater.Namespace + "/" + ref.API.SecretRef.Name
+		}
+	}
+	if spec.ServiceAccountName != "" {
+		key += ",serviceAccount=" + updater.Namespace + "/" + spec.ServiceAccountName
+	}
+	return key
+}
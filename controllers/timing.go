@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/timing"
+)
+
+// deferUpdate records the image as the candidate in the updater's status, and
+// returns how long to wait before it can be applied, or zero if it can be
+// applied now.
+//
+// The lastUpdate is the last time that any updater changed the target.
+func deferUpdate(updater *appsv1alpha1.ImagePolicyArgoCDUpdate, image string, lastUpdate, now time.Time) time.Duration {
+	status := &updater.Status
+	if status.CandidateImage != image || status.CandidateSince == nil {
+		status.CandidateImage = image
+		since := metav1.NewTime(now)
+		status.CandidateSince = &since
+	}
+	return updateWindows(updater).Wait(now, lastUpdate, status.CandidateSince.Time)
+}
+
+// lastTargetUpdate returns the last time that one of the updaters changed
+// their target, the updaters must all write to the same target.
+func lastTargetUpdate(updaters []*appsv1alpha1.ImagePolicyArgoCDUpdate) time.Time {
+	var last time.Time
+	for _, updater := range updaters {
+		if t := timeOrZero(updater.Status.LastUpdateTime); t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// recordUpdate records that the image was applied in the updater's status.
+func recordUpdate(updater *appsv1alpha1.ImagePolicyArgoCDUpdate, image string, pinned bool, now time.Time) {
+	updated := metav1.NewTime(now)
+	updater.Status.LastUpdateTime = &updated
+	clearCandidate(updater)
+	recordHistory(updater, image, pinned, now)
+}
+
+// clearCandidate removes the candidate image from the updater's status.
+func clearCandidate(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) {
+	updater.Status.CandidateImage = ""
+	updater.Status.CandidateSince = nil
+}
+
+func updateWindows(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) timing.Windows {
+	var w timing.Windows
+	if updater.Spec.MinInterval != nil {
+		w.MinInterval = updater.Spec.MinInterval.Duration
+	}
+	if updater.Spec.StabilizationWindow != nil {
+		w.StabilizationWindow = updater.Spec.StabilizationWindow.Duration
+	}
+	return w
+}
+
+func timeOrZero(t *metav1.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.Time
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/selection"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/update"
+)
+
+// updaterResult is the outcome of reconciling one updater against the update
+// target that it shares with other updaters.
+type updaterResult struct {
+	updater *appsv1alpha1.ImagePolicyArgoCDUpdate
+
+	// status, reason and message are recorded in the Ready condition, the
+	// status is not updated if there is no reason.
+	status  corev1.ConditionStatus
+	reason  string
+	message string
+
+	// requeueAfter is when the updater should be reconciled again.
+	requeueAfter time.Duration
+	// err is a failure that is retried according to the updater's retry
+	// policy.
+	err error
+
+	// image is the image that was applied to the target's source, it is
+	// empty if the updater didn't apply an image.
+	image         string
+	previousImage string
+	// pinned is true if the image is the updater's pinned image.
+	pinned bool
+	// changed is true if the updater changed the target's source.
+	changed bool
+}
+
+func (u *updaterResult) setStatus(status corev1.ConditionStatus, reason, message string) {
+	u.status, u.reason, u.message = status, reason, message
+}
+
+func (u *updaterResult) fail(reason string, err error) {
+	u.setStatus(corev1.ConditionFalse, reason, err.Error())
+}
+
+// reconcileUpdater applies the updater's latest image to the target's source,
+// if it passes the updater's checks.
+//
+// The target is not saved, so that the changes from all the updaters for the
+// target can be written together. The lastUpdate is the last time that any of
+// them changed the target.
+func (r *ImagePolicyArgoCDUpdateReconciler) reconcileUpdater(ctx context.Context, logger logger, target updateTarget, updater *appsv1alpha1.ImagePolicyArgoCDUpdate, lastUpdate, now time.Time) *updaterResult {
+	res := &updaterResult{updater: updater}
+	kubeClient, err := r.clientFor(updater)
+	if err != nil {
+		logger.error(err, "failed to create a client for the update policy", "serviceAccountName", updater.Spec.ServiceAccountName)
+		res.err = err
+		return res
+	}
+
+	imagePolicy, err := r.loadImagePolicy(ctx, kubeClient, updater.Namespace, updater.Spec.ImagePolicyRef)
+	if err != nil {
+		logger.error(err, "failed to load the image policy")
+		if apiStatusReason(err) == metav1.StatusReasonNotFound {
+			res.fail(appsv1alpha1.ImagePolicyNotFoundReason, err)
+		}
+		res.err = err
+		return res
+	}
+	logger.info("loaded the image policy", "imagePolicy", imagePolicy.Name)
+
+	source, err := sourceFor(target, updater)
+	if err != nil {
+		logger.error(err, "failed to select the source")
+		res.fail(appsv1alpha1.InvalidSpecReason, err)
+		res.err = err
+		return res
+	}
+
+	recordImages(updater, imagePolicy)
+	sel, err := selection.Select(updater, imagePolicy, source)
+	if err != nil {
+		logger.error(err, "failed to parse the tag filter")
+		res.fail(appsv1alpha1.InvalidSpecReason, err)
+		return res
+	}
+	latestImage, pinned, previousImage := sel.Image, sel.Pinned, sel.Current
+	if latestImage == "" {
+		logger.info("the image policy has no latest image")
+		// The ImagePolicy is watched, selecting an image will trigger a new
+		// reconciliation.
+		res.setStatus(corev1.ConditionFalse, appsv1alpha1.NoLatestImageReason, fmt.Sprintf("the ImagePolicy %s has no latest image yet", imagePolicy.Name))
+		return res
+	}
+	if sel.Rejection != nil {
+		logger.info("the tag filter rejected the image", "newImage", latestImage, "reason", sel.Rejection.Error())
+		message := sel.Rejection.Error()
+		if previousImage != "" {
+			message = fmt.Sprintf("%s, keeping the current image %s", sel.Rejection, previousImage)
+		}
+		res.setStatus(corev1.ConditionTrue, appsv1alpha1.FilteredReason, message)
+		return res
+	}
+	if !pinned {
+		setDowngradeBlocked(updater, sel.Downgrade)
+	}
+	if sel.Downgrade != nil {
+		logger.info("refusing to downgrade the image", "previousImage", previousImage, "newImage", latestImage)
+		// A newer image, or a change to the spec, will trigger a new
+		// reconciliation.
+		res.fail(appsv1alpha1.DowngradeBlockedReason, sel.Downgrade)
+		return res
+	}
+	if isVerifiedImage(updater, previousImage, latestImage) {
+		// The image was pinned to its verified digest when it was applied.
+		latestImage = previousImage
+	}
+	if previousImage == latestImage {
+		clearCandidate(updater)
+	} else {
+		if wait := updateWait(updater, latestImage, pinned, lastUpdate, now); wait > 0 {
+			logger.info("deferring the update", "newImage", latestImage, "wait", wait)
+			message := fmt.Sprintf("image %s will be applied after %s", latestImage, now.Add(wait).UTC().Format(time.RFC3339))
+			res.setStatus(corev1.ConditionFalse, appsv1alpha1.UpdateDeferredReason, message)
+			res.requeueAfter = wait
+			return res
+		}
+
+		err := r.checkImageExists(ctx, kubeClient, updater, latestImage)
+		if reasonOf(err, "") == appsv1alpha1.ImageNotFoundReason {
+			logger.info("deferring the update until the image exists", "newImage", latestImage)
+			res.fail(appsv1alpha1.ImageNotFoundReason, err)
+			res.requeueAfter = updater.Spec.ImageCheck.GetRetryInterval()
+			return res
+		}
+		if err != nil {
+			logger.error(err, "failed to check that the image exists")
+			res.fail(reasonOf(err, appsv1alpha1.RegistryErrorReason), err)
+			res.err = err
+			return res
+		}
+
+		verifiedImage, err := r.verifyImage(ctx, kubeClient, updater, latestImage)
+		if reasonOf(err, "") == appsv1alpha1.VerificationFailedReason {
+			setVerificationFailed(updater, err)
+			logger.info("refusing to apply an image that failed verification", "newImage", latestImage, "reason", err.Error())
+			res.fail(appsv1alpha1.VerificationFailedReason, err)
+			res.requeueAfter = verificationRetryInterval
+			return res
+		}
+		if err != nil {
+			logger.error(err, "failed to verify the image")
+			res.fail(reasonOf(err, appsv1alpha1.RegistryErrorReason), err)
+			res.err = err
+			return res
+		}
+		setVerificationFailed(updater, nil)
+		latestImage = verifiedImage
+	}
+
+	// The strategy is applied to a copy, so that a failure doesn't leave a
+	// partial change in the source that other updaters share.
+	src := source.DeepCopy()
+	err = update.Apply(src, updater.Spec.Strategy, latestImage)
+	res.changed = !equality.Semantic.DeepEqual(src, source)
+	if err == nil && res.changed {
+		err = recordProvenance(target, updater, imagePolicy, previousImage, latestImage, now)
+	}
+	if err != nil {
+		logger.error(err, "failed to apply the update strategy")
+		// The spec or the image needs to change to fix this, either will
+		// trigger a new reconciliation.
+		res.changed = false
+		res.fail(updateErrorReason(err), err)
+		return res
+	}
+	*source = *src
+	res.image = latestImage
+	res.previousImage = previousImage
+	res.pinned = pinned
+	message := "applied image " + latestImage
+	if pinned {
+		message = "applied pinned image " + latestImage
+	}
+	res.setStatus(corev1.ConditionTrue, appsv1alpha1.ReconciliationSucceededReason, message)
+	return res
+}
+
+// updateWait returns how long to wait before the image can be applied, pinned
+// images are applied immediately.
+func updateWait(updater *appsv1alpha1.ImagePolicyArgoCDUpdate, image string, pinned bool, lastUpdate, now time.Time) time.Duration {
+	if pinned {
+		clearCandidate(updater)
+		return 0
+	}
+	return deferUpdate(updater, image, lastUpdate, now)
+}
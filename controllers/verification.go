@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/cosign"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/update"
+)
+
+// verificationRetryInterval is how long to wait before checking again for a
+// valid signature, signatures are often pushed after the image.
+const verificationRetryInterval = 5 * time.Minute
+
+// verifyImage checks the image's cosign signature, if the updater requires
+// verification, and returns the image pinned to the verified digest, so that
+// the signed image is deployed even if the tag is moved.
+//
+// The signature is fetched from the same registry, with the same credentials,
+// as the ImageCheck.
+func (r *ImagePolicyArgoCDUpdateReconciler) verifyImage(ctx context.Context, c client.Client, updater *appsv1alpha1.ImagePolicyArgoCDUpdate, image string) (string, error) {
+	verification := updater.Spec.Verification
+	if verification == nil {
+		return image, nil
+	}
+	keys, err := loadPublicKeys(ctx, c, updater.Namespace, verification.PublicKeys)
+	if err != nil {
+		return "", withReason(appsv1alpha1.PublicKeysErrorReason, err)
+	}
+	registryClient, ref, err := r.registryReference(ctx, c, updater, image)
+	if err != nil {
+		return "", err
+	}
+	digest, err := cosign.Verify(ctx, registryClient, ref, keys)
+	var verificationErr *cosign.VerificationError
+	if errors.As(err, &verificationErr) {
+		return "", withReason(appsv1alpha1.VerificationFailedReason, err)
+	}
+	if err != nil {
+		return "", withReason(appsv1alpha1.RegistryErrorReason, err)
+	}
+	img := update.ParseImage(image)
+	img.Digest = digest
+	return img.String(), nil
+}
+
+// isVerifiedImage returns true if the current image is the latest image,
+// pinned to a digest by verifyImage.
+func isVerifiedImage(updater *appsv1alpha1.ImagePolicyArgoCDUpdate, current, latest string) bool {
+	if updater.Spec.Verification == nil || update.ParseImage(latest).Digest != "" {
+		return false
+	}
+	img := update.ParseImage(current)
+	if img.Digest == "" {
+		return false
+	}
+	img.Digest = ""
+	return img.String() == latest
+}
+
+// setVerificationFailed records the VerificationFailed condition in the
+// updater's status.
+func setVerificationFailed(updater *appsv1alpha1.ImagePolicyArgoCDUpdate, failed error) {
+	setBlockedCondition(updater, appsv1alpha1.VerificationFailedCondition, appsv1alpha1.VerificationFailedReason, failed, "the latest image has a valid signature")
+}
+
+func loadPublicKeys(ctx context.Context, c client.Client, ns string, ref appsv1alpha1.PublicKeysReference) ([]*ecdsa.PublicKey, error) {
+	var data map[string][]byte
+	switch {
+	case (ref.SecretRef == nil) == (ref.ConfigMapRef == nil):
+		return nil, errors.New("exactly one of secretRef and configMapRef must be provided for the public keys")
+	case ref.SecretRef != nil:
+		secret, err := loadSecret(ctx, c, ns, ref.SecretRef.Name)
+		if err != nil {
+			return nil, err
+		}
+		data = secret.Data
+	default:
+		name := types.NamespacedName{Name: ref.ConfigMapRef.Name, Namespace: ns}
+		var configMap corev1.ConfigMap
+		if err := c.Get(ctx, name, &configMap); err != nil {
+			return nil, fmt.Errorf("failed to load the ConfigMap %s: %w", name, err)
+		}
+		data = map[string][]byte{}
+		for k, v := range configMap.Data {
+			data[k] = []byte(v)
+		}
+		for k, v := range configMap.BinaryData {
+			data[k] = v
+		}
+	}
+	keys, err := cosign.ParsePublicKeys(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the public keys: %w", err)
+	}
+	return keys, nil
+}
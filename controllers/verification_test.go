@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/cosign"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/registry"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/registry/registrytest"
+)
+
+const (
+	testImage     = "registry.example.com/bigkevmcd/go-demo"
+	testImageRepo = "bigkevmcd/go-demo"
+)
+
+var testImageManifest = []byte(`{"schemaVersion":2}`)
+
+func TestReconcileVerifiesInTheMirrorWithThePullSecrets(t *testing.T) {
+	reg := registrytest.NewServer(t)
+	reg.RequireCredentials("testuser", "testpass")
+	key := generateTestKey(t)
+	digest := reg.AddManifest(testImageRepo, "v1.1.0", "application/vnd.oci.image.manifest.v1+json", testImageManifest)
+	signTestImage(t, reg, key, digest)
+	updater := makeVerifiedUpdater(reg)
+	c := newApplicationsClient(t, updater,
+		makeTestPolicy("go-demo", testImage, "v1.1.0"),
+		makeTestApplication(testImage+":v1.0.0"),
+		makePullSecret(t, reg.Host(), "testuser", "testpass"),
+		makePublicKeys(t, key))
+	r := newTestReconciler(c)
+	r.registryClient = registry.NewClient(reg.Client())
+
+	reconcileTarget(t, r, updater)
+
+	pinned := testImage + ":v1.1.0@" + digest
+	assertApplicationImages(t, c, pinned)
+	assertReady(t, c, "test-updater", corev1.ConditionTrue, appsv1alpha1.ReconciliationSucceededReason, "applied image "+pinned)
+
+	reconcileTarget(t, r, updater)
+
+	if c.updates != 1 {
+		t.Fatalf("got %d updates to the Application, want 1", c.updates)
+	}
+	assertApplicationImages(t, c, pinned)
+}
+
+func TestReconcileRefusesUnsignedImages(t *testing.T) {
+	reg := registrytest.NewServer(t)
+	reg.RequireCredentials("testuser", "testpass")
+	key := generateTestKey(t)
+	reg.AddManifest(testImageRepo, "v1.1.0", "application/vnd.oci.image.manifest.v1+json", testImageManifest)
+	updater := makeVerifiedUpdater(reg)
+	c := newApplicationsClient(t, updater,
+		makeTestPolicy("go-demo", testImage, "v1.1.0"),
+		makeTestApplication(testImage+":v1.0.0"),
+		makePullSecret(t, reg.Host(), "testuser", "testpass"),
+		makePublicKeys(t, key))
+	r := newTestReconciler(c)
+	r.registryClient = registry.NewClient(reg.Client())
+
+	result := reconcileTarget(t, r, updater)
+
+	if c.updates != 0 {
+		t.Fatalf("got %d updates to the Application, want 0", c.updates)
+	}
+	if result.RequeueAfter != verificationRetryInterval {
+		t.Fatalf("got RequeueAfter %s, want %s", result.RequeueAfter, verificationRetryInterval)
+	}
+	assertReady(t, c, "test-updater", corev1.ConditionFalse, appsv1alpha1.VerificationFailedReason,
+		"failed to verify the signature of "+reg.Host()+"/"+testImageRepo+":v1.1.0: no signature found")
+}
+
+func TestIsVerifiedImage(t *testing.T) {
+	verifiedTests := []struct {
+		name         string
+		verification bool
+		current      string
+		latest       string
+		want         bool
+	}{
+		{"pinned to a digest", true, "go-demo:v1.1.0@sha256:1234", "go-demo:v1.1.0", true},
+		{"without verification", false, "go-demo:v1.1.0@sha256:1234", "go-demo:v1.1.0", false},
+		{"not pinned", true, "go-demo:v1.1.0", "go-demo:v1.1.0", false},
+		{"another tag", true, "go-demo:v1.0.0@sha256:1234", "go-demo:v1.1.0", false},
+		{"latest has a digest", true, "go-demo:v1.1.0@sha256:1234", "go-demo:v1.1.0@sha256:5678", false},
+	}
+
+	for _, tt := range verifiedTests {
+		t.Run(tt.name, func(t *testing.T) {
+			updater := makeTestUpdater("test-updater", "go-demo")
+			if tt.verification {
+				updater.Spec.Verification = &appsv1alpha1.VerificationSpec{}
+			}
+
+			if got := isVerifiedImage(updater, tt.current, tt.latest); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// makeVerifiedUpdater returns an updater that verifies images in the
+// registry, which mirrors registry.example.com.
+func makeVerifiedUpdater(reg *registrytest.Server) *appsv1alpha1.ImagePolicyArgoCDUpdate {
+	updater := makeTestUpdater("test-updater", "go-demo")
+	updater.Spec.ImageCheck = &appsv1alpha1.ImageCheckSpec{
+		Mirror:      reg.Host(),
+		PullSecrets: []corev1.LocalObjectReference{{Name: "pull-secret"}},
+	}
+	updater.Spec.Verification = &appsv1alpha1.VerificationSpec{
+		PublicKeys: appsv1alpha1.PublicKeysReference{ConfigMapRef: &corev1.LocalObjectReference{Name: "cosign-keys"}},
+	}
+	return updater
+}
+
+func makePullSecret(t *testing.T, host, username, password string) *corev1.Secret {
+	t.Helper()
+	config, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			host: map[string]string{"username": username, "password": password},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "pull-secret", Namespace: updaterNamespace},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: config},
+	}
+}
+
+func makePublicKeys(t *testing.T, key *ecdsa.PrivateKey) *corev1.ConfigMap {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cosign-keys", Namespace: updaterNamespace},
+		Data:       map[string]string{"cosign.pub": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))},
+	}
+}
+
+func generateTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// signTestImage stores a cosign signature of the image with the digest in the
+// registry.
+func signTestImage(t *testing.T, reg *registrytest.Server, key *ecdsa.PrivateKey, digest string) {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"%s"},"image":{"docker-manifest-digest":"%s"},"type":"%s"},"optional":null}`, testImage, digest, cosign.SignatureType))
+	h := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"layers": []map[string]interface{}{
+			{
+				"digest":      reg.AddBlob(payload),
+				"size":        len(payload),
+				"annotations": map[string]string{cosign.SignatureAnnotation: base64.StdEncoding.EncodeToString(sig)},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.AddManifest(testImageRepo, cosign.SignatureTag(digest), "application/vnd.oci.image.manifest.v1+json", manifest)
+}
@@ -3,41 +3,19 @@ module github.com/bigkevmcd/image-policy-argo-updater
 go 1.13
 
 require (
-	github.com/argoproj/argo-cd v1.6.2
+	github.com/Masterminds/semver v1.5.0
+	github.com/evanphx/json-patch v4.5.0+incompatible
 	github.com/fluxcd/image-reflector-controller v0.0.0-20200819120130-b302367aac9e
 	github.com/go-logr/logr v0.1.0
 	github.com/google/go-cmp v0.4.1
 	github.com/onsi/ginkgo v1.12.1
 	github.com/onsi/gomega v1.10.1
+	go.uber.org/zap v1.10.0
+	golang.org/x/time v0.0.0-20200416051211-89c76fbcd5d1
+	gopkg.in/yaml.v3 v3.0.1
 	k8s.io/api v0.18.6
 	k8s.io/apimachinery v0.18.6
-	k8s.io/client-go v11.0.1-0.20190816222228-6d55c1b1f1ca+incompatible
+	k8s.io/client-go v0.18.6
 	sigs.k8s.io/controller-runtime v0.6.2
-	sigs.k8s.io/kustomize v2.0.3+incompatible
-)
-
-// Pin k8s dependencies to v0.16.6 for ArgoCD
-replace (
-	k8s.io/api => k8s.io/api v0.16.6
-	k8s.io/apiextensions-apiserver => k8s.io/apiextensions-apiserver v0.16.6
-	k8s.io/apimachinery => k8s.io/apimachinery v0.16.6
-	k8s.io/apiserver => k8s.io/apiserver v0.16.6
-	k8s.io/cli-runtime => k8s.io/cli-runtime v0.16.6
-	k8s.io/client-go => k8s.io/client-go v0.16.6
-	k8s.io/cloud-provider => k8s.io/cloud-provider v0.16.6
-	k8s.io/cluster-bootstrap => k8s.io/cluster-bootstrap v0.16.6
-	k8s.io/code-generator => k8s.io/code-generator v0.16.6
-	k8s.io/component-base => k8s.io/component-base v0.16.6
-	k8s.io/cri-api => k8s.io/cri-api v0.16.6
-	k8s.io/csi-translation-lib => k8s.io/csi-translation-lib v0.16.6
-	k8s.io/kube-aggregator => k8s.io/kube-aggregator v0.16.6
-	k8s.io/kube-controller-manager => k8s.io/kube-controller-manager v0.16.6
-	k8s.io/kube-proxy => k8s.io/kube-proxy v0.16.6
-	k8s.io/kube-scheduler => k8s.io/kube-scheduler v0.16.6
-	k8s.io/kubectl => k8s.io/kubectl v0.16.6
-	k8s.io/kubelet => k8s.io/kubelet v0.16.6
-	k8s.io/legacy-cloud-providers => k8s.io/legacy-cloud-providers v0.16.6
-	k8s.io/metrics => k8s.io/metrics v0.16.6
-	k8s.io/sample-apiserver => k8s.io/sample-apiserver v0.16.6
-	sigs.k8s.io/controller-runtime => sigs.k8s.io/controller-runtime v0.5.1
+	sigs.k8s.io/yaml v1.2.0
 )
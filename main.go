@@ -16,18 +16,24 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
 
+	uberzap "go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
-	argov1alpha1 "github.com/argoproj/argo-cd/pkg/apis/application/v1alpha1"
 	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
 	"github.com/bigkevmcd/image-policy-argo-updater/controllers"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/apiwait"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/config"
 	imagev1alpha1 "github.com/fluxcd/image-reflector-controller/api/v1alpha1"
 	// +kubebuilder:scaffold:imports
 )
@@ -41,46 +47,150 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(imagev1alpha1.AddToScheme(scheme))
 	utilruntime.Must(appsv1alpha1.AddToScheme(scheme))
-	utilruntime.Must(argov1alpha1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
-	var metricsAddr string
-	var enableLeaderElection bool
-	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
-	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
-		"Enable leader election for controller manager. "+
-			"Enabling this will ensure there is only one active controller manager.")
+	configFile := defineFlags(flag.CommandLine)
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+	cfg, err := loadConfig(flag.CommandLine, *configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		Port:               9443,
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "8dd9c525.bigkevmcd.com",
-	})
+	level := uberzap.NewAtomicLevelAt(cfg.ZapLevel())
+	ctrl.SetLogger(zap.New(zap.UseDevMode(true), zap.Level(&level)))
+
+	syncPeriod := cfg.Controller.SyncPeriod.Duration
+	options := ctrl.Options{
+		Scheme:                 scheme,
+		MetricsBindAddress:     cfg.MetricsBindAddress,
+		HealthProbeBindAddress: cfg.HealthProbeBindAddress,
+		Port:                   9443,
+		LeaderElection:         cfg.LeaderElection,
+		LeaderElectionID:       "8dd9c525.bigkevmcd.com",
+		SyncPeriod:             &syncPeriod,
+	}
+	if cached := cfg.CacheNamespaces(); len(cached) > 0 {
+		if len(cached) == 1 {
+			options.Namespace = cached[0]
+		} else {
+			options.NewCache = cache.MultiNamespacedCacheBuilder(cached)
+		}
+		setupLog.Info("restricting the manager to namespaces", "namespaces", cached)
+	}
+
+	restConfig := ctrl.GetConfigOrDie()
+	restConfig.QPS = cfg.Client.QPS
+	restConfig.Burst = cfg.Client.Burst
+	mgr, err := ctrl.NewManager(restConfig, options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.ImagePolicyArgoCDUpdateReconciler{
+	reconciler := &controllers.ImagePolicyArgoCDUpdateReconciler{
 		Client: mgr.GetClient(),
 		Log:    ctrl.Log.WithName("controllers").WithName("ImagePolicyArgoCDUpdate"),
 		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "ImagePolicyArgoCDUpdate")
+		Config: mgr.GetConfig(),
+		Mapper: mgr.GetRESTMapper(),
+
+		Namespaces:              cfg.WatchNamespaces,
+		ApplicationNamespaces:   cfg.AllowedApplicationNamespaces(),
+		MaxConcurrentReconciles: cfg.Controller.MaxConcurrentReconciles,
+		RateLimiter:             cfg.Controller.RateLimiter.RateLimiter(),
+		RequeueInterval:         cfg.Controller.RequeueInterval.Duration,
+	}
+	if err := reconciler.SetupIndexes(mgr); err != nil {
+		setupLog.Error(err, "unable to create the indexes", "controller", "ImagePolicyArgoCDUpdate")
+		os.Exit(1)
+	}
+	// The ArgoCD and Flux CRDs might not be installed yet, the controller is
+	// started when they are, and the manager isn't ready until then.
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		setupLog.Error(err, "unable to create the discovery client")
+		os.Exit(1)
+	}
+	waiter := apiwait.New(discoveryClient, controllers.RequiredResources, func() error {
+		return reconciler.SetupController(mgr)
+	}, ctrl.Log.WithName("apiwait"))
+	if err := mgr.Add(waiter); err != nil {
+		setupLog.Error(err, "unable to wait for the APIs")
 		os.Exit(1)
 	}
 	// +kubebuilder:scaffold:builder
 
+	if cfg.HealthProbeBindAddress != "" {
+		if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+			setupLog.Error(err, "unable to add the health check")
+			os.Exit(1)
+		}
+		if err := mgr.AddReadyzCheck("apis", waiter.Check); err != nil {
+			setupLog.Error(err, "unable to add the readiness check")
+			os.Exit(1)
+		}
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// defineFlags defines the manager's flags in the flag set, and returns the
+// path of the configuration file.
+func defineFlags(fs *flag.FlagSet) *string {
+	configFile := fs.String("config", "",
+		"The manager configuration file, flags that are set override the values in the file.")
+	fs.String("metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	fs.String("health-probe-addr", "", "The address the /healthz and /readyz endpoints bind to, they are disabled if this is empty.")
+	fs.Bool("enable-leader-election", false,
+		"Enable leader election for controller manager. "+
+			"Enabling this will ensure there is only one active controller manager.")
+	fs.String("watch-namespaces", "",
+		"Comma-separated namespaces to reconcile updaters in, all namespaces are reconciled if this is empty.")
+	fs.String("application-namespaces", "",
+		"Comma-separated namespaces of the Applications that can be updated. "+
+			"This defaults to the watched namespaces, or all namespaces if no namespaces are watched.")
+	fs.String("log-level", config.DebugLevel, "The log level, one of debug, info or error.")
+	return configFile
+}
+
+// loadConfig loads the configuration file, if there is one, and overrides it
+// with the flags that were set.
+func loadConfig(fs *flag.FlagSet, path string) (*config.ManagerConfig, error) {
+	cfg := config.Default()
+	if path != "" {
+		loaded, err := config.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		cfg = loaded
+	}
+	fs.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		switch f.Name {
+		case "metrics-addr":
+			cfg.MetricsBindAddress = value
+		case "health-probe-addr":
+			cfg.HealthProbeBindAddress = value
+		case "enable-leader-election":
+			cfg.LeaderElection = value == "true"
+		case "watch-namespaces":
+			cfg.WatchNamespaces = config.SplitNamespaces(value)
+		case "application-namespaces":
+			cfg.ApplicationNamespaces = config.SplitNamespaces(value)
+		case "log-level":
+			cfg.LogLevel = value
+		}
+	})
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/config"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file := `apiVersion: config.apps.bigkevmcd.com/v1alpha1
+kind: ManagerConfig
+watchNamespaces:
+- team-a
+applicationNamespaces:
+- argocd
+logLevel: info
+`
+	if err := ioutil.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	configFile := defineFlags(fs)
+	if err := fs.Parse([]string{"-config", path, "-watch-namespaces", "team-a, team-b", "-enable-leader-election"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(fs, *configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := config.Default()
+	want.WatchNamespaces = []string{"team-a", "team-b"}
+	want.ApplicationNamespaces = []string{"argocd"}
+	want.LogLevel = config.InfoLevel
+	want.LeaderElection = true
+	if diff := cmp.Diff(want, cfg); diff != "" {
+		t.Fatalf("failed to load the configuration:\n%s", diff)
+	}
+}
+
+func TestLoadConfigWithoutAFile(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	configFile := defineFlags(fs)
+	if err := fs.Parse([]string{"-application-namespaces", "argocd"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(fs, *configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := config.Default()
+	want.ApplicationNamespaces = []string{"argocd"}
+	if diff := cmp.Diff(want, cfg); diff != "" {
+		t.Fatalf("failed to load the configuration:\n%s", diff)
+	}
+}
+
+func TestLoadConfigInvalidFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	configFile := defineFlags(fs)
+	if err := fs.Parse([]string{"-log-level", "trace"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := loadConfig(fs, *configFile)
+
+	if err == nil {
+		t.Fatal("expected the log level to be rejected")
+	}
+}
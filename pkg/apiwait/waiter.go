@@ -0,0 +1,164 @@
+// Package apiwait waits for the APIs that controllers use to be served, before
+// starting the controllers.
+package apiwait
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+)
+
+// DefaultInterval is how often the APIs are checked while they are missing.
+const DefaultInterval = 10 * time.Second
+
+// Waiter polls API discovery until all the resources are served, and then
+// calls its start function once.
+//
+// It implements the manager's Runnable interface, and Check can be used as a
+// readiness check, which fails until the start function has been called.
+type Waiter struct {
+	discovery discovery.DiscoveryInterface
+	resources []schema.GroupVersionResource
+	start     func() error
+	log       logr.Logger
+
+	// Interval is how often the APIs are checked, it defaults to the
+	// DefaultInterval.
+	Interval time.Duration
+
+	mu      sync.Mutex
+	missing []schema.GroupVersionResource
+	err     error
+	started bool
+}
+
+// New creates a Waiter that calls start once all the resources are served.
+func New(d discovery.DiscoveryInterface, resources []schema.GroupVersionResource, start func() error, log logr.Logger) *Waiter {
+	return &Waiter{
+		discovery: d,
+		resources: resources,
+		start:     start,
+		log:       log,
+		Interval:  DefaultInterval,
+		missing:   resources,
+	}
+}
+
+// Start polls until the resources are served, or the stop channel is closed.
+//
+// An error from the start function is returned, which stops the manager.
+func (w *Waiter) Start(stop <-chan struct{}) error {
+	var startErr error
+	err := wait.PollImmediateUntil(w.Interval, func() (bool, error) {
+		missing, err := Missing(w.discovery, w.resources)
+		w.mu.Lock()
+		w.missing, w.err = missing, err
+		w.mu.Unlock()
+		if err != nil {
+			w.log.Error(err, "failed to discover the APIs")
+			return false, nil
+		}
+		if len(missing) > 0 {
+			w.log.Info("waiting for APIs to be installed", "missing", missing)
+			return false, nil
+		}
+		w.log.Info("the APIs are installed, starting the controllers")
+		if startErr = w.start(); startErr != nil {
+			return false, startErr
+		}
+		w.mu.Lock()
+		w.started = true
+		w.mu.Unlock()
+		return true, nil
+	}, stop)
+	if startErr != nil {
+		return startErr
+	}
+	if err == wait.ErrWaitTimeout {
+		// The stop channel was closed before the APIs were installed.
+		return nil
+	}
+	return err
+}
+
+// NeedLeaderElection returns false, so that every replica reports whether
+// the APIs are installed.
+func (w *Waiter) NeedLeaderElection() bool {
+	return false
+}
+
+// Check returns an error until the resources are served and the start
+// function has been called.
+func (w *Waiter) Check(_ *http.Request) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch {
+	case w.started:
+		return nil
+	case w.err != nil:
+		return fmt.Errorf("failed to discover the APIs: %w", w.err)
+	case len(w.missing) > 0:
+		return fmt.Errorf("waiting for APIs to be installed: %v", w.missing)
+	}
+	return fmt.Errorf("waiting for the controllers to start")
+}
+
+// Missing returns the resources that are not served by the API server.
+//
+// If a resource has no Version, it is served if any version of its group
+// serves it.
+func Missing(d discovery.DiscoveryInterface, resources []schema.GroupVersionResource) ([]schema.GroupVersionResource, error) {
+	groups, err := d.ServerGroups()
+	if err != nil {
+		return nil, err
+	}
+	versions := map[string][]string{}
+	for _, g := range groups.Groups {
+		for _, v := range g.Versions {
+			versions[g.Name] = append(versions[g.Name], v.Version)
+		}
+	}
+
+	names := map[schema.GroupVersion]map[string]bool{}
+	served := func(gv schema.GroupVersion, resource string) (bool, error) {
+		if names[gv] == nil {
+			list, err := d.ServerResourcesForGroupVersion(gv.String())
+			if err != nil {
+				return false, err
+			}
+			names[gv] = map[string]bool{}
+			for _, res := range list.APIResources {
+				names[gv][res.Name] = true
+			}
+		}
+		return names[gv][resource], nil
+	}
+
+	var missing []schema.GroupVersionResource
+	for _, r := range resources {
+		found := false
+		for _, v := range versions[r.Group] {
+			if r.Version != "" && r.Version != v {
+				continue
+			}
+			ok, err := served(schema.GroupVersion{Group: r.Group, Version: v}, r.Resource)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, r)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,163 @@
+package apiwait
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var (
+	imagePolicies = schema.GroupVersionResource{Group: "image.toolkit.fluxcd.io", Version: "v1alpha1", Resource: "imagepolicies"}
+	applications  = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"}
+)
+
+func TestMissing(t *testing.T) {
+	d := newFakeDiscovery(
+		resourceList("argoproj.io/v1alpha1", "applications", "appprojects"),
+		resourceList("image.toolkit.fluxcd.io/v1alpha1", "imagerepositories"),
+	)
+
+	missing, err := Missing(d, []schema.GroupVersionResource{imagePolicies, applications})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]schema.GroupVersionResource{imagePolicies}, missing); diff != "" {
+		t.Fatalf("incorrect missing resources:\n%s", diff)
+	}
+}
+
+func TestMissingWithMissingGroup(t *testing.T) {
+	d := newFakeDiscovery(resourceList("image.toolkit.fluxcd.io/v1alpha1", "imagepolicies"))
+
+	missing, err := Missing(d, []schema.GroupVersionResource{imagePolicies, applications})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]schema.GroupVersionResource{applications}, missing); diff != "" {
+		t.Fatalf("incorrect missing resources:\n%s", diff)
+	}
+}
+
+func TestMissingWithAnyVersion(t *testing.T) {
+	anyImagePolicies := schema.GroupVersionResource{Group: "image.toolkit.fluxcd.io", Resource: "imagepolicies"}
+	d := newFakeDiscovery(
+		resourceList("image.toolkit.fluxcd.io/v1beta1", "imagerepositories"),
+		resourceList("image.toolkit.fluxcd.io/v1beta2", "imagepolicies"),
+	)
+
+	missing, err := Missing(d, []schema.GroupVersionResource{anyImagePolicies, applications})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]schema.GroupVersionResource{applications}, missing); diff != "" {
+		t.Fatalf("incorrect missing resources:\n%s", diff)
+	}
+}
+
+func TestWaiterStartsWhenTheAPIsAreInstalled(t *testing.T) {
+	d := newFakeDiscovery(resourceList("argoproj.io/v1alpha1", "applications"))
+	started := make(chan struct{})
+	w := New(d, []schema.GroupVersionResource{imagePolicies, applications}, func() error {
+		close(started)
+		return nil
+	}, log.NullLogger{})
+	w.Interval = 10 * time.Millisecond
+	stop := make(chan struct{})
+	defer close(stop)
+	done := make(chan error)
+	go func() {
+		done <- w.Start(stop)
+	}()
+
+	want := "waiting for APIs to be installed: [image.toolkit.fluxcd.io/v1alpha1, Resource=imagepolicies]"
+	waitFor(t, func() bool {
+		err := w.Check(nil)
+		return err != nil && err.Error() == want
+	})
+	select {
+	case <-started:
+		t.Fatal("started before the APIs were installed")
+	default:
+	}
+
+	d.Lock()
+	d.Resources = append(d.Resources, resourceList("image.toolkit.fluxcd.io/v1alpha1", "imagepolicies"))
+	d.Unlock()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not started after the APIs were installed")
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Check(nil); err != nil {
+		t.Fatalf("got readiness error %v, want ready", err)
+	}
+}
+
+func TestWaiterReturnsStartErrors(t *testing.T) {
+	d := newFakeDiscovery(resourceList("argoproj.io/v1alpha1", "applications"))
+	w := New(d, []schema.GroupVersionResource{applications}, func() error {
+		return errors.New("failed to start")
+	}, log.NullLogger{})
+	stop := make(chan struct{})
+	defer close(stop)
+
+	err := w.Start(stop)
+
+	if err == nil || err.Error() != "failed to start" {
+		t.Fatalf("got error %v, want the start error", err)
+	}
+	if err := w.Check(nil); err == nil {
+		t.Fatal("got ready, want not ready")
+	}
+}
+
+func TestWaiterStopsWhileWaiting(t *testing.T) {
+	w := New(newFakeDiscovery(), []schema.GroupVersionResource{applications}, func() error {
+		t.Fatal("unexpected start")
+		return nil
+	}, log.NullLogger{})
+	w.Interval = 10 * time.Millisecond
+	stop := make(chan struct{})
+	close(stop)
+
+	if err := w.Start(stop); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newFakeDiscovery(lists ...*metav1.APIResourceList) *fakediscovery.FakeDiscovery {
+	return &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: lists}}
+}
+
+func resourceList(gv string, names ...string) *metav1.APIResourceList {
+	list := &metav1.APIResourceList{GroupVersion: gv}
+	for _, name := range names {
+		list.APIResources = append(list.APIResources, metav1.APIResource{Name: name})
+	}
+	return list
+}
+
+func waitFor(t *testing.T, f func() bool) {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		if f() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for the condition")
+}
@@ -0,0 +1,229 @@
+// Package application reads and edits ArgoCD Applications as Unstructured
+// objects, with a typed view of the fields that the updater edits.
+//
+// Fields that are not in the view are left as they are when the changes are
+// written back, so this works with Application schemas that have fields that
+// this package doesn't know about.
+//
+// +kubebuilder:object:generate=true
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+)
+
+// GroupVersionKind is the kind of ArgoCD Applications.
+var GroupVersionKind = schema.GroupVersionKind{
+	Group:   "argoproj.io",
+	Version: "v1alpha1",
+	Kind:    "Application",
+}
+
+// ApplicationSetGroupVersionKind is the kind of ArgoCD ApplicationSets.
+var ApplicationSetGroupVersionKind = schema.GroupVersionKind{
+	Group:   "argoproj.io",
+	Version: "v1alpha1",
+	Kind:    "ApplicationSet",
+}
+
+// templateSpecPath is the path to the spec of the Applications that an
+// ApplicationSet generates.
+var templateSpecPath = []string{"spec", "template", "spec"}
+
+// Application is an ArgoCD Application, or the Application template of an
+// ApplicationSet.
+//
+// +kubebuilder:object:generate=false
+type Application struct {
+	// Object is the resource as it was loaded, Apply writes the changes to
+	// the Spec into it.
+	Object *unstructured.Unstructured
+
+	// Spec is the view of the fields that are edited.
+	Spec ApplicationSpec
+
+	loaded ApplicationSpec
+	path   []string
+}
+
+// NewObject returns an empty Application, for reading Applications.
+func NewObject() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(GroupVersionKind)
+	return u
+}
+
+// NewApplicationSetObject returns an empty ApplicationSet, for reading
+// ApplicationSets.
+func NewApplicationSetObject() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(ApplicationSetGroupVersionKind)
+	return u
+}
+
+// New returns the view of the Application in the Unstructured object.
+func New(obj *unstructured.Unstructured) (*Application, error) {
+	return newAt(obj, "spec")
+}
+
+// NewTemplate returns the view of the Application template in an
+// ApplicationSet.
+func NewTemplate(obj *unstructured.Unstructured) (*Application, error) {
+	return newAt(obj, templateSpecPath...)
+}
+
+func newAt(obj *unstructured.Unstructured, path ...string) (*Application, error) {
+	app := &Application{Object: obj, path: path}
+	spec, _, err := unstructured.NestedMap(obj.Object, path...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s of %s %s/%s: %w", strings.Join(path, "."), obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &app.Spec); err != nil {
+		return nil, fmt.Errorf("failed to parse %s of %s %s/%s: %w", strings.Join(path, "."), obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+	}
+	app.Spec.DeepCopyInto(&app.loaded)
+	return app, nil
+}
+
+// SelectSource returns the index in AllSources of the source that the
+// selector matches.
+//
+// If the selector is nil, the Application must have a single source.
+func (in *ApplicationSpec) SelectSource(selector *appsv1alpha1.SourceSelector) (int, error) {
+	sources := in.AllSources()
+	if selector == nil || *selector == (appsv1alpha1.SourceSelector{}) {
+		if len(sources) > 1 {
+			return 0, fmt.Errorf("there are %d sources, the source to update must be selected", len(sources))
+		}
+		return 0, nil
+	}
+	set := 0
+	for _, v := range []bool{selector.Index != nil, selector.RepoURL != "", selector.Name != ""} {
+		if v {
+			set++
+		}
+	}
+	if set > 1 {
+		return 0, errors.New("only one of index, repoURL and name can select the source")
+	}
+	if selector.Index != nil {
+		i := int(*selector.Index)
+		if i < 0 || i >= len(sources) {
+			return 0, fmt.Errorf("source index %d is out of range, there are %d sources", i, len(sources))
+		}
+		return i, nil
+	}
+	field, value := "name", selector.Name
+	if selector.RepoURL != "" {
+		field, value = "repoURL", selector.RepoURL
+	}
+	found := -1
+	for i, src := range sources {
+		v := src.Name
+		if field == "repoURL" {
+			v = src.RepoURL
+		}
+		if v != value {
+			continue
+		}
+		if found >= 0 {
+			return 0, fmt.Errorf("more than one source has %s %q", field, value)
+		}
+		found = i
+	}
+	if found < 0 {
+		return 0, fmt.Errorf("no source has %s %q", field, value)
+	}
+	return found, nil
+}
+
+// Apply writes the changes to the Spec into the Object, only the fields that
+// changed are written.
+//
+// The Sources are changed in place, they can't be added or removed.
+func (a *Application) Apply() error {
+	spec, _, err := unstructured.NestedMap(a.Object.Object, a.path...)
+	if err != nil {
+		return fmt.Errorf("failed to read the spec: %w", err)
+	}
+	if len(a.Spec.Sources) != len(a.loaded.Sources) {
+		return errors.New("the sources can't be added or removed")
+	}
+	// A JSON merge patch replaces lists, so the sources are patched one by
+	// one, to keep their fields that are not in the view.
+	current, loaded := a.Spec.DeepCopy(), a.loaded.DeepCopy()
+	current.Sources, loaded.Sources = nil, nil
+	spec, err = mergeChanges(spec, loaded, current)
+	if err != nil {
+		return err
+	}
+	if len(a.Spec.Sources) > 0 {
+		sources, _, err := unstructured.NestedSlice(spec, "sources")
+		if err != nil || len(sources) != len(a.Spec.Sources) {
+			return errors.New("failed to read the sources")
+		}
+		for i := range sources {
+			src, ok := sources[i].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("failed to read source %d", i)
+			}
+			if sources[i], err = mergeChanges(src, a.loaded.Sources[i], a.Spec.Sources[i]); err != nil {
+				return err
+			}
+		}
+		if err := unstructured.SetNestedSlice(spec, sources, "sources"); err != nil {
+			return fmt.Errorf("failed to update the sources: %w", err)
+		}
+	}
+	if err := unstructured.SetNestedMap(a.Object.Object, spec, a.path...); err != nil {
+		return fmt.Errorf("failed to update the spec: %w", err)
+	}
+	a.Spec.DeepCopyInto(&a.loaded)
+	return nil
+}
+
+// mergeChanges applies the changes between the loaded and current views to
+// the original value.
+func mergeChanges(original map[string]interface{}, loaded, current interface{}) (map[string]interface{}, error) {
+	before, err := json.Marshal(loaded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the spec: %w", err)
+	}
+	after, err := json.Marshal(current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the spec: %w", err)
+	}
+	patch, err := jsonpatch.CreateMergePatch(before, after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the spec patch: %w", err)
+	}
+	if original == nil {
+		original = map[string]interface{}{}
+	}
+	b, err := json.Marshal(original)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the spec: %w", err)
+	}
+	patched, err := jsonpatch.MergePatch(b, patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch the spec: %w", err)
+	}
+	// This unmarshals numbers as int64 where possible, as Unstructured
+	// objects require.
+	updated := map[string]interface{}{}
+	if err := utiljson.Unmarshal(patched, &updated); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the spec: %w", err)
+	}
+	return updated, nil
+}
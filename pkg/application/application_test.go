@@ -0,0 +1,290 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+)
+
+func TestNew(t *testing.T) {
+	app, err := New(makeApplication())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := ApplicationSpec{
+		Source: ApplicationSource{
+			RepoURL: "https://github.com/bigkevmcd/go-demo.git",
+			Path:    "examples/kustomize/overlays/dev",
+			Kustomize: &ApplicationSourceKustomize{
+				Images: KustomizeImages{"bigkevmcd/go-demo:v1.0.0"},
+			},
+		},
+		Info: []Info{{Name: "owner", Value: "team-a"}},
+	}
+	if diff := cmp.Diff(want, app.Spec); diff != "" {
+		t.Fatalf("failed to parse the spec:\n%s", diff)
+	}
+}
+
+func TestApply(t *testing.T) {
+	app, err := New(makeApplication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.Spec.Source.Kustomize.Images[0] = "bigkevmcd/go-demo:v1.1.0"
+	app.Spec.Info = nil
+
+	if err := app.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := makeApplication()
+	want.Object["spec"] = map[string]interface{}{
+		"project": "default",
+		"source": map[string]interface{}{
+			"repoURL": "https://github.com/bigkevmcd/go-demo.git",
+			"path":    "examples/kustomize/overlays/dev",
+			"kustomize": map[string]interface{}{
+				"namePrefix": "dev-",
+				"images":     []interface{}{"bigkevmcd/go-demo:v1.1.0"},
+			},
+			"ref": "values",
+		},
+		"syncPolicy": map[string]interface{}{
+			"retry": map[string]interface{}{"limit": int64(5)},
+		},
+	}
+	if diff := cmp.Diff(want, app.Object); diff != "" {
+		t.Fatalf("failed to apply the changes:\n%s", diff)
+	}
+}
+
+func TestApplyInfo(t *testing.T) {
+	obj := makeApplication()
+	spec := obj.Object["spec"].(map[string]interface{})
+	spec["info"] = append(spec["info"].([]interface{}),
+		map[string]interface{}{"name": "Image updated by image-policy-argo-updater", "value": "updaters/go-demo applied bigkevmcd/go-demo:v1.0.0"},
+		map[string]interface{}{"name": "runbook", "value": "https://example.com/runbook"},
+	)
+	app, err := New(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The provenance entry is updated in place, and the link is added, the
+	// entries owned by other tools are left alone.
+	app.Spec.Info[1].Value = "updaters/go-demo applied bigkevmcd/go-demo:v1.1.0"
+	app.Spec.Info = append(app.Spec.Info, Info{Name: "Image update details", Value: "https://example.com/v1.1.0"})
+
+	if err := app.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []interface{}{
+		map[string]interface{}{"name": "owner", "value": "team-a"},
+		map[string]interface{}{"name": "Image updated by image-policy-argo-updater", "value": "updaters/go-demo applied bigkevmcd/go-demo:v1.1.0"},
+		map[string]interface{}{"name": "runbook", "value": "https://example.com/runbook"},
+		map[string]interface{}{"name": "Image update details", "value": "https://example.com/v1.1.0"},
+	}
+	if diff := cmp.Diff(want, app.Object.Object["spec"].(map[string]interface{})["info"]); diff != "" {
+		t.Fatalf("failed to apply the info:\n%s", diff)
+	}
+}
+
+func TestApplyWithMultipleSources(t *testing.T) {
+	obj := makeApplication()
+	spec := obj.Object["spec"].(map[string]interface{})
+	spec["sources"] = []interface{}{
+		map[string]interface{}{
+			"repoURL": "https://charts.example.com",
+			"chart":   "go-demo",
+			"helm": map[string]interface{}{
+				"valueFiles": []interface{}{"$values/values.yaml"},
+			},
+		},
+		map[string]interface{}{
+			"repoURL": "https://github.com/bigkevmcd/go-demo.git",
+			"ref":     "values",
+		},
+	}
+	delete(spec, "source")
+	app, err := New(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.Spec.Sources[0].Helm.Parameters = []HelmParameter{{Name: "image.tag", Value: "v1.1.0"}}
+
+	if err := app.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []interface{}{
+		map[string]interface{}{
+			"repoURL": "https://charts.example.com",
+			"chart":   "go-demo",
+			"helm": map[string]interface{}{
+				"valueFiles": []interface{}{"$values/values.yaml"},
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "image.tag", "value": "v1.1.0"},
+				},
+			},
+		},
+		map[string]interface{}{
+			"repoURL": "https://github.com/bigkevmcd/go-demo.git",
+			"ref":     "values",
+		},
+	}
+	if diff := cmp.Diff(want, app.Object.Object["spec"].(map[string]interface{})["sources"]); diff != "" {
+		t.Fatalf("failed to apply the changes:\n%s", diff)
+	}
+}
+
+func TestApplyTemplate(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetKind("ApplicationSet")
+	obj.Object["spec"] = map[string]interface{}{
+		"generators": []interface{}{},
+		"template": map[string]interface{}{
+			"spec": map[string]interface{}{
+				"source": map[string]interface{}{
+					"repoURL": "https://github.com/bigkevmcd/go-demo.git",
+					"path":    "{{path}}",
+				},
+			},
+		},
+	}
+	app, err := NewTemplate(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.Spec.Source.Kustomize = &ApplicationSourceKustomize{Images: KustomizeImages{"bigkevmcd/go-demo:v1.1.0"}}
+
+	if err := app.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"generators": []interface{}{},
+		"template": map[string]interface{}{
+			"spec": map[string]interface{}{
+				"source": map[string]interface{}{
+					"repoURL": "https://github.com/bigkevmcd/go-demo.git",
+					"path":    "{{path}}",
+					"kustomize": map[string]interface{}{
+						"images": []interface{}{"bigkevmcd/go-demo:v1.1.0"},
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, obj.Object["spec"]); diff != "" {
+		t.Fatalf("failed to apply the changes:\n%s", diff)
+	}
+}
+
+func TestSelectSource(t *testing.T) {
+	spec := ApplicationSpec{
+		Sources: []ApplicationSource{
+			{Name: "chart", RepoURL: "https://charts.example.com"},
+			{Name: "values", RepoURL: "https://github.com/bigkevmcd/go-demo.git"},
+			{RepoURL: "https://github.com/bigkevmcd/go-demo.git"},
+		},
+	}
+	index := func(i int32) *int32 { return &i }
+	selectTests := []struct {
+		name     string
+		selector *appsv1alpha1.SourceSelector
+		want     int
+		wantErr  string
+	}{
+		{"by index", &appsv1alpha1.SourceSelector{Index: index(1)}, 1, ""},
+		{"by name", &appsv1alpha1.SourceSelector{Name: "chart"}, 0, ""},
+		{"by repoURL", &appsv1alpha1.SourceSelector{RepoURL: "https://charts.example.com"}, 0, ""},
+		{"no selector", nil, 0, "there are 3 sources, the source to update must be selected"},
+		{"index out of range", &appsv1alpha1.SourceSelector{Index: index(3)}, 0, "source index 3 is out of range, there are 3 sources"},
+		{"ambiguous repoURL", &appsv1alpha1.SourceSelector{RepoURL: "https://github.com/bigkevmcd/go-demo.git"}, 0, `more than one source has repoURL "https://github.com/bigkevmcd/go-demo.git"`},
+		{"unknown name", &appsv1alpha1.SourceSelector{Name: "unknown"}, 0, `no source has name "unknown"`},
+		{"several fields", &appsv1alpha1.SourceSelector{Name: "chart", Index: index(0)}, 0, "only one of index, repoURL and name can select the source"},
+	}
+
+	for _, tt := range selectTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := spec.SelectSource(tt.selector)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got source %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectSourceWithSingleSource(t *testing.T) {
+	spec := ApplicationSpec{Source: ApplicationSource{RepoURL: "https://github.com/bigkevmcd/go-demo.git"}}
+
+	got, err := spec.SelectSource(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != 0 || spec.AllSources()[got] != &spec.Source {
+		t.Fatalf("got source %d, want the single source", got)
+	}
+}
+
+func TestKustomizeImagesFind(t *testing.T) {
+	findTests := []struct {
+		image KustomizeImage
+		want  int
+	}{
+		{"bigkevmcd/go-demo:v1.1.0", 1},
+		{"bigkevmcd/other:v1.1.0", -1},
+		{"bigkevmcd/go-demo", -1},
+	}
+
+	images := KustomizeImages{"nginx:1.19", "bigkevmcd/go-demo:v1.0.0"}
+	for _, tt := range findTests {
+		t.Run(string(tt.image), func(t *testing.T) {
+			if got := images.Find(tt.image); got != tt.want {
+				t.Fatalf("Find(%q) got %d, want %d", tt.image, got, tt.want)
+			}
+		})
+	}
+}
+
+// makeApplication returns an Application with fields that are not in the
+// view.
+func makeApplication() *unstructured.Unstructured {
+	app := NewObject()
+	app.SetName("go-demo")
+	app.SetNamespace("argocd")
+	app.Object["spec"] = map[string]interface{}{
+		"project": "default",
+		"source": map[string]interface{}{
+			"repoURL": "https://github.com/bigkevmcd/go-demo.git",
+			"path":    "examples/kustomize/overlays/dev",
+			"kustomize": map[string]interface{}{
+				"namePrefix": "dev-",
+				"images":     []interface{}{"bigkevmcd/go-demo:v1.0.0"},
+			},
+			"ref": "values",
+		},
+		"info": []interface{}{
+			map[string]interface{}{"name": "owner", "value": "team-a"},
+		},
+		"syncPolicy": map[string]interface{}{
+			"retry": map[string]interface{}{"limit": int64(5)},
+		},
+	}
+	return app
+}
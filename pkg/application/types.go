@@ -0,0 +1,149 @@
+package application
+
+import "strings"
+
+// ApplicationSpec is the view of the ArgoCD ApplicationSpec.
+//
+// Applications have either a Source, or several Sources.
+type ApplicationSpec struct {
+	Source  ApplicationSource   `json:"source,omitempty"`
+	Sources []ApplicationSource `json:"sources,omitempty"`
+	Info    []Info              `json:"info,omitempty"`
+}
+
+// AllSources returns the Sources of a multi-source Application, or its single
+// Source.
+func (in *ApplicationSpec) AllSources() []*ApplicationSource {
+	if len(in.Sources) == 0 {
+		return []*ApplicationSource{&in.Source}
+	}
+	sources := make([]*ApplicationSource, len(in.Sources))
+	for i := range in.Sources {
+		sources[i] = &in.Sources[i]
+	}
+	return sources
+}
+
+// ApplicationSource is the view of the ArgoCD ApplicationSource.
+type ApplicationSource struct {
+	Name           string                      `json:"name,omitempty"`
+	RepoURL        string                      `json:"repoURL,omitempty"`
+	Path           string                      `json:"path,omitempty"`
+	TargetRevision string                      `json:"targetRevision,omitempty"`
+	Chart          string                      `json:"chart,omitempty"`
+	Helm           *ApplicationSourceHelm      `json:"helm,omitempty"`
+	Kustomize      *ApplicationSourceKustomize `json:"kustomize,omitempty"`
+	Directory      *ApplicationSourceDirectory `json:"directory,omitempty"`
+	Plugin         *ApplicationSourcePlugin    `json:"plugin,omitempty"`
+}
+
+// ApplicationSourceHelm holds the Helm parameters and values.
+type ApplicationSourceHelm struct {
+	Parameters []HelmParameter `json:"parameters,omitempty"`
+	Values     string          `json:"values,omitempty"`
+}
+
+// AddParameter adds the parameter, replacing any parameter with the same
+// name.
+func (in *ApplicationSourceHelm) AddParameter(p HelmParameter) {
+	for i, cp := range in.Parameters {
+		if cp.Name == p.Name {
+			in.Parameters[i] = p
+			return
+		}
+	}
+	in.Parameters = append(in.Parameters, p)
+}
+
+// HelmParameter is a parameter passed to Helm.
+type HelmParameter struct {
+	Name        string `json:"name,omitempty"`
+	Value       string `json:"value,omitempty"`
+	ForceString bool   `json:"forceString,omitempty"`
+}
+
+// ApplicationSourceKustomize holds the Kustomize images, labels and
+// annotations.
+type ApplicationSourceKustomize struct {
+	Images            KustomizeImages   `json:"images,omitempty"`
+	CommonLabels      map[string]string `json:"commonLabels,omitempty"`
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+}
+
+// KustomizeImage is a Kustomize image override, for example
+// "nginx:1.19" or "nginx=bigkevmcd/nginx:1.19".
+type KustomizeImage string
+
+func (i KustomizeImage) delim() string {
+	for _, d := range []string{"=", ":", "@"} {
+		if strings.Contains(string(i), d) {
+			return d
+		}
+	}
+	return ":"
+}
+
+// Match returns true if the images have the same name.
+func (i KustomizeImage) Match(j KustomizeImage) bool {
+	delim := j.delim()
+	if !strings.Contains(string(j), delim) {
+		return false
+	}
+	return strings.HasPrefix(string(i), strings.Split(string(j), delim)[0])
+}
+
+// KustomizeImages is a list of Kustomize image overrides.
+type KustomizeImages []KustomizeImage
+
+// Find returns the index of the image with the same name, or -1 if there is
+// none.
+func (images KustomizeImages) Find(image KustomizeImage) int {
+	for i, a := range images {
+		if a.Match(image) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ApplicationSourceDirectory holds the Jsonnet variables of a directory
+// source.
+type ApplicationSourceDirectory struct {
+	Jsonnet ApplicationSourceJsonnet `json:"jsonnet,omitempty"`
+}
+
+// ApplicationSourceJsonnet holds the Jsonnet external variables and
+// top-level arguments.
+type ApplicationSourceJsonnet struct {
+	ExtVars []JsonnetVar `json:"extVars,omitempty"`
+	TLAs    []JsonnetVar `json:"tlas,omitempty"`
+}
+
+// JsonnetVar is a Jsonnet variable.
+type JsonnetVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Code  bool   `json:"code,omitempty"`
+}
+
+// ApplicationSourcePlugin holds the environment of a config management
+// plugin.
+type ApplicationSourcePlugin struct {
+	Name string `json:"name,omitempty"`
+	Env  `json:"env,omitempty"`
+}
+
+// Env is the environment of a config management plugin.
+type Env []*EnvEntry
+
+// EnvEntry is an environment variable of a config management plugin.
+type EnvEntry struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Info is an entry in the Application's spec.info.
+type Info struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
@@ -0,0 +1,180 @@
+package argocd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+const (
+	// ServerKey is the key in a Secret that holds the ArgoCD API server URL.
+	ServerKey = "server"
+	// TokenKey is the key in a Secret that holds the ArgoCD API token.
+	TokenKey = "token"
+)
+
+// requestTimeout limits the time for each request to the API server, so that
+// an unresponsive server doesn't block reconciliation.
+const requestTimeout = 30 * time.Second
+
+var applicationsResource = schema.GroupResource{Group: "argoproj.io", Resource: "applications"}
+
+// Client is a client for the ArgoCD API server's REST API.
+type Client struct {
+	serverURL  string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient creates and returns a new Client for the server, authenticating
+// with the token.
+func NewClient(serverURL, token string, httpClient *http.Client) *Client {
+	return &Client{
+		serverURL:  strings.TrimSuffix(serverURL, "/"),
+		token:      token,
+		httpClient: httpClient,
+	}
+}
+
+// ClientFromSecret creates a Client from the server URL and token in the
+// Secret.
+//
+// If insecure is true, the server's TLS certificate is not verified.
+// Requests time out after 30 seconds.
+func ClientFromSecret(secret *corev1.Secret, insecure bool) (*Client, error) {
+	server, ok := secret.Data[ServerKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no key %q", secret.Namespace, secret.Name, ServerKey)
+	}
+	token, ok := secret.Data[TokenKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no key %q", secret.Namespace, secret.Name, TokenKey)
+	}
+	httpClient := &http.Client{Timeout: requestTimeout}
+	if insecure {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		httpClient.Transport = transport
+	}
+	return NewClient(strings.TrimSpace(string(server)), strings.TrimSpace(string(token)), httpClient), nil
+}
+
+// GetApplication fetches the named Application in the namespace.
+//
+// Applications in ArgoCD's own namespace can be fetched with an empty
+// namespace, other namespaces need ArgoCD's "applications in any namespace".
+//
+// If the Application does not exist, the error is a Kubernetes NotFound error,
+// and authentication and authorization failures are Kubernetes Unauthorized
+// and Forbidden errors.
+func (c *Client) GetApplication(ctx context.Context, namespace, name string) (*unstructured.Unstructured, error) {
+	obj := map[string]interface{}{}
+	if err := c.do(ctx, http.MethodGet, namespace, name, "", nil, &obj); err != nil {
+		return nil, err
+	}
+	// The API server doesn't always include the kind.
+	app := &unstructured.Unstructured{Object: obj}
+	app.SetGroupVersionKind(application.GroupVersionKind)
+	return app, nil
+}
+
+// PatchApplication applies a JSON merge patch to the named Application in the
+// namespace.
+//
+// If the patch has a metadata.resourceVersion and the Application has changed
+// since, the error is a Kubernetes Conflict error.
+func (c *Client) PatchApplication(ctx context.Context, namespace, name string, patch []byte) error {
+	body := map[string]string{
+		"name":      name,
+		"patch":     string(patch),
+		"patchType": "merge",
+	}
+	if namespace != "" {
+		body["appNamespace"] = namespace
+	}
+	return c.do(ctx, http.MethodPatch, namespace, name, "", body, nil)
+}
+
+// SyncApplication triggers a sync of the named Application in the namespace.
+func (c *Client) SyncApplication(ctx context.Context, namespace, name string) error {
+	body := map[string]string{"name": name}
+	if namespace != "" {
+		body["appNamespace"] = namespace
+	}
+	return c.do(ctx, http.MethodPost, namespace, name, "/sync", body, nil)
+}
+
+// do sends the request for the Application, the namespace is sent as the
+// appNamespace query parameter, requests with a body also need it in the
+// body.
+func (c *Client) do(ctx context.Context, method, namespace, name, suffix string, body, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal the request body: %w", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	u := c.serverURL + "/api/v1/applications/" + url.PathEscape(name) + suffix
+	if namespace != "" {
+		u += "?" + url.Values{"appNamespace": {namespace}}.Encode()
+	}
+	req, err := http.NewRequest(method, u, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create the request: %w", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the ArgoCD API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return apierrors.NewNotFound(applicationsResource, name)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("ArgoCD API request %s %s failed with status %d: %s", method, req.URL.Path, resp.StatusCode, strings.TrimSpace(string(msg)))
+		// These are returned as Kubernetes errors, so that they are handled
+		// in the same way as access through the Kubernetes API.
+		switch resp.StatusCode {
+		case http.StatusUnauthorized:
+			return apierrors.NewUnauthorized(err.Error())
+		case http.StatusForbidden:
+			return apierrors.NewForbidden(applicationsResource, name, err)
+		case http.StatusConflict:
+			return apierrors.NewConflict(applicationsResource, name, err)
+		}
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode the ArgoCD API response: %w", err)
+	}
+	return nil
+}
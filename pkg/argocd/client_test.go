@@ -0,0 +1,326 @@
+package argocd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+const testToken = "test-token"
+
+func TestGetApplication(t *testing.T) {
+	api := newStubAPI(t)
+	api.apps["my-app"] = makeApp("my-app")
+	client := NewClient(api.URL, testToken, api.Client())
+
+	app, err := client.GetApplication(context.Background(), "", "my-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := makeApp("my-app")
+	want.SetGroupVersionKind(application.GroupVersionKind)
+	if diff := cmp.Diff(want, app); diff != "" {
+		t.Fatalf("failed to get the application:\n%s", diff)
+	}
+}
+
+func TestGetApplicationNotFound(t *testing.T) {
+	api := newStubAPI(t)
+	client := NewClient(api.URL, testToken, api.Client())
+
+	_, err := client.GetApplication(context.Background(), "", "unknown-app")
+
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("got error %v, want a NotFound error", err)
+	}
+}
+
+func TestGetApplicationWithBadToken(t *testing.T) {
+	api := newStubAPI(t)
+	api.apps["my-app"] = makeApp("my-app")
+	client := NewClient(api.URL, "bad-token", api.Client())
+
+	_, err := client.GetApplication(context.Background(), "", "my-app")
+
+	if !apierrors.IsUnauthorized(err) || !strings.Contains(err.Error(), "failed with status 401") {
+		t.Fatalf("got error %v, want an unauthorized error", err)
+	}
+}
+
+func TestGetApplicationForbidden(t *testing.T) {
+	api := newStubAPI(t)
+	api.apps["my-app"] = makeApp("my-app")
+	api.forbidden = true
+	client := NewClient(api.URL, testToken, api.Client())
+
+	_, err := client.GetApplication(context.Background(), "", "my-app")
+
+	if !apierrors.IsForbidden(err) || !strings.Contains(err.Error(), "failed with status 403") {
+		t.Fatalf("got error %v, want a forbidden error", err)
+	}
+}
+
+func TestPatchApplication(t *testing.T) {
+	api := newStubAPI(t)
+	api.apps["my-app"] = makeApp("my-app")
+	client := NewClient(api.URL, testToken, api.Client())
+
+	patch := []byte(`{"spec":{"source":{"kustomize":{"commonAnnotations":{"app.example.com/version":"v1"}}}}}`)
+	if err := client.PatchApplication(context.Background(), "", "my-app", patch); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []patchRequest{{Name: "my-app", Patch: string(patch), PatchType: "merge"}}
+	if diff := cmp.Diff(want, api.patches); diff != "" {
+		t.Fatalf("failed to patch the application:\n%s", diff)
+	}
+}
+
+func TestPatchApplicationConflict(t *testing.T) {
+	api := newStubAPI(t)
+	api.apps["my-app"] = makeApp("my-app")
+	client := NewClient(api.URL, testToken, api.Client())
+
+	err := client.PatchApplication(context.Background(), "", "my-app", []byte(`{"metadata":{"resourceVersion":"1"}}`))
+
+	if !apierrors.IsConflict(err) || !strings.Contains(err.Error(), "failed with status 409") {
+		t.Fatalf("got error %v, want a conflict error", err)
+	}
+}
+
+func TestApplicationNamespace(t *testing.T) {
+	api := newStubAPI(t)
+	api.apps["my-app"] = makeApp("my-app")
+	client := NewClient(api.URL, testToken, api.Client())
+	ctx := context.Background()
+
+	if _, err := client.GetApplication(ctx, "team-a", "my-app"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.PatchApplication(ctx, "team-a", "my-app", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SyncApplication(ctx, "team-a", "my-app"); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"team-a", "team-a", "team-a"}, api.namespaces); diff != "" {
+		t.Fatalf("failed to send the namespace:\n%s", diff)
+	}
+	want := []patchRequest{{Name: "my-app", AppNamespace: "team-a", Patch: "{}", PatchType: "merge"}}
+	if diff := cmp.Diff(want, api.patches); diff != "" {
+		t.Fatalf("failed to send the namespace in the patch:\n%s", diff)
+	}
+	if diff := cmp.Diff([]syncRequest{{Name: "my-app", AppNamespace: "team-a"}}, api.syncRequests); diff != "" {
+		t.Fatalf("failed to send the namespace in the sync:\n%s", diff)
+	}
+}
+
+func TestSyncApplication(t *testing.T) {
+	api := newStubAPI(t)
+	api.apps["my-app"] = makeApp("my-app")
+	client := NewClient(api.URL, testToken, api.Client())
+
+	if err := client.SyncApplication(context.Background(), "", "my-app"); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"my-app"}, api.synced); diff != "" {
+		t.Fatalf("failed to sync the application:\n%s", diff)
+	}
+}
+
+func TestClientFromSecret(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "argocd-api", Namespace: "test-ns"},
+		Data: map[string][]byte{
+			ServerKey: []byte("https://argocd.example.com/\n"),
+			TokenKey:  []byte(testToken + "\n"),
+		},
+	}
+
+	client, err := ClientFromSecret(secret, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if client.serverURL != "https://argocd.example.com" {
+		t.Errorf("got server %q, want %q", client.serverURL, "https://argocd.example.com")
+	}
+	if client.token != testToken {
+		t.Errorf("got token %q, want %q", client.token, testToken)
+	}
+	if client.httpClient.Timeout != requestTimeout {
+		t.Errorf("got timeout %s, want %s", client.httpClient.Timeout, requestTimeout)
+	}
+	if client.httpClient.Transport != nil {
+		t.Errorf("got transport %v, want the default", client.httpClient.Transport)
+	}
+}
+
+func TestClientFromSecretInsecure(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "argocd-api", Namespace: "test-ns"},
+		Data: map[string][]byte{
+			ServerKey: []byte("https://argocd.example.com"),
+			TokenKey:  []byte(testToken),
+		},
+	}
+
+	client, err := ClientFromSecret(secret, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if client.httpClient.Timeout != requestTimeout {
+		t.Errorf("got timeout %s, want %s", client.httpClient.Timeout, requestTimeout)
+	}
+	transport, ok := client.httpClient.Transport.(*http.Transport)
+	if !ok || transport == http.DefaultTransport || !transport.TLSClientConfig.InsecureSkipVerify {
+		t.Fatalf("got transport %v, want a copy of the default that skips verification", client.httpClient.Transport)
+	}
+	if tlsConfig := http.DefaultTransport.(*http.Transport).TLSClientConfig; tlsConfig != nil && tlsConfig.InsecureSkipVerify {
+		t.Fatal("the default transport was modified")
+	}
+}
+
+func TestClientFromSecretMissingKeys(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "argocd-api", Namespace: "test-ns"},
+		Data: map[string][]byte{
+			ServerKey: []byte("https://argocd.example.com"),
+		},
+	}
+
+	_, err := ClientFromSecret(secret, false)
+
+	if err == nil || err.Error() != `secret test-ns/argocd-api has no key "token"` {
+		t.Fatalf("got error %v, want missing token", err)
+	}
+}
+
+// stubAPI is a minimal implementation of the ArgoCD applications API.
+type stubAPI struct {
+	*httptest.Server
+	t       *testing.T
+	apps    map[string]*unstructured.Unstructured
+	synced  []string
+	patches []patchRequest
+	// syncRequests and namespaces are the bodies of the sync requests, and
+	// the appNamespace query parameter of every request.
+	syncRequests []syncRequest
+	namespaces   []string
+	// forbidden rejects all requests with a valid token.
+	forbidden bool
+}
+
+type patchRequest struct {
+	Name         string `json:"name"`
+	AppNamespace string `json:"appNamespace,omitempty"`
+	Patch        string `json:"patch"`
+	PatchType    string `json:"patchType"`
+}
+
+type syncRequest struct {
+	Name         string `json:"name"`
+	AppNamespace string `json:"appNamespace,omitempty"`
+}
+
+func newStubAPI(t *testing.T) *stubAPI {
+	api := &stubAPI{t: t, apps: map[string]*unstructured.Unstructured{}}
+	api.Server = httptest.NewServer(http.HandlerFunc(api.handle))
+	t.Cleanup(api.Close)
+	return api
+}
+
+func (s *stubAPI) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+testToken {
+		http.Error(w, "invalid session", http.StatusUnauthorized)
+		return
+	}
+	if s.forbidden {
+		http.Error(w, "permission denied", http.StatusForbidden)
+		return
+	}
+	if ns := r.URL.Query().Get("appNamespace"); ns != "" {
+		s.namespaces = append(s.namespaces, ns)
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/applications/"), "/")
+	app, ok := s.apps[parts[0]]
+	if !ok {
+		http.Error(w, "application not found", http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 1:
+		s.writeJSON(w, app.Object)
+	case r.Method == http.MethodPatch && len(parts) == 1:
+		var patch patchRequest
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.patches = append(s.patches, patch)
+		var changes unstructured.Unstructured
+		if err := json.Unmarshal([]byte(patch.Patch), &changes.Object); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if rv := changes.GetResourceVersion(); rv != "" && rv != app.GetResourceVersion() {
+			http.Error(w, "the object has been modified", http.StatusConflict)
+			return
+		}
+		s.writeJSON(w, app.Object)
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[1] == "sync":
+		var sync syncRequest
+		if err := json.NewDecoder(r.Body).Decode(&sync); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.syncRequests = append(s.syncRequests, sync)
+		s.synced = append(s.synced, app.GetName())
+		s.writeJSON(w, app.Object)
+	default:
+		http.Error(w, "not implemented", http.StatusNotImplemented)
+	}
+}
+
+func (s *stubAPI) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// makeApp returns an Application as the API server returns it, without the
+// kind, and with fields that aren't in the application package's view.
+func makeApp(name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":            name,
+				"namespace":       "argocd",
+				"resourceVersion": "2",
+			},
+			"spec": map[string]interface{}{
+				"source": map[string]interface{}{
+					"repoURL": "https://github.com/bigkevmcd/go-demo.git",
+					"path":    "examples/kustomize/overlays/dev",
+				},
+				"project": "default",
+			},
+		},
+	}
+}
@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/imagepolicy"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/selection"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/update"
+)
+
+// List writes a table of the updaters in the namespace, or all namespaces if
+// the namespace is empty.
+func List(ctx context.Context, c client.Client, policies imagepolicy.Reader, out io.Writer, namespace string) error {
+	var updaters appsv1alpha1.ImagePolicyArgoCDUpdateList
+	if err := c.List(ctx, &updaters, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("failed to list the updaters: %w", err)
+	}
+	sort.Slice(updaters.Items, func(i, j int) bool {
+		a, b := updaters.Items[i], updaters.Items[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tTARGET\tIMAGEPOLICY\tCURRENT\tCANDIDATE\tSTATUS")
+	for i := range updaters.Items {
+		updater := &updaters.Items[i]
+		// A missing ImagePolicy is shown as no candidate.
+		imagePolicy, _ := loadImagePolicy(ctx, c, policies, updater)
+		candidate, pinned := selection.DesiredImage(updater, imagePolicy)
+		current := "<unknown>"
+		if src, err := loadSource(ctx, c, updater); err == nil {
+			current = update.CurrentImage(src, updater.Spec.Strategy, candidate)
+		} else if err == errRemoteTarget {
+			current = "<remote>"
+		}
+		if pinned {
+			candidate += " (pinned)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", updater.Namespace, updater.Name, targetName(updater),
+			updater.Spec.ImagePolicyRef.Name, orNone(current), orNone(candidate), status(updater))
+	}
+	return w.Flush()
+}
+
+// Diff writes the changes that the updater would make to its target's source.
+//
+// The image is selected as the controller selects it, but the registry checks,
+// that the image exists and is signed, are not run, and the image is not
+// pinned to its digest.
+func Diff(ctx context.Context, c client.Client, policies imagepolicy.Reader, out io.Writer, name types.NamespacedName) error {
+	updater, err := loadUpdater(ctx, c, name)
+	if err != nil {
+		return err
+	}
+	imagePolicy, err := loadImagePolicy(ctx, c, policies, updater)
+	if err != nil && updater.Spec.PinnedImage == "" {
+		return fmt.Errorf("failed to load the ImagePolicy: %w", err)
+	}
+	src, err := loadSource(ctx, c, updater)
+	if err != nil {
+		return fmt.Errorf("failed to load the source of %s: %w", targetName(updater), err)
+	}
+	sel, err := selection.Select(updater, imagePolicy, src)
+	if err != nil {
+		return fmt.Errorf("failed to parse the tag filter: %w", err)
+	}
+	image := sel.Image
+	if image == "" {
+		return fmt.Errorf("the ImagePolicy %s has no latest image", updater.Spec.ImagePolicyRef.Name)
+	}
+	blocked := sel.Rejection
+	if blocked == nil {
+		blocked = sel.Downgrade
+	}
+	if blocked != nil {
+		fmt.Fprintf(out, "%s keeps its current image, image %s is not applied: %s\n", targetName(updater), image, blocked)
+		return nil
+	}
+	updated := src.DeepCopy()
+	if err := update.Apply(updated, updater.Spec.Strategy, image); err != nil {
+		return fmt.Errorf("failed to apply image %s: %w", image, err)
+	}
+	diff := cmp.Diff(src, updated)
+	if diff == "" {
+		fmt.Fprintf(out, "%s already has image %s\n", targetName(updater), image)
+		return nil
+	}
+	fmt.Fprintf(out, "%s source changes for image %s:\n%s", targetName(updater), image, diff)
+	if updater.Spec.ImageCheck != nil || updater.Spec.Verification != nil {
+		fmt.Fprintf(out, "The registry checks are not run, the controller may wait for image %s, or apply it by digest.\n", image)
+	}
+	return nil
+}
+
+// RequestReconcile annotates the updater so that the controller reconciles it
+// immediately.
+func RequestReconcile(ctx context.Context, c client.Client, name types.NamespacedName, now time.Time) error {
+	return patchUpdater(ctx, c, name, func(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) {
+		annotations := updater.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[appsv1alpha1.ReconcileRequestedAnnotation] = now.UTC().Format(time.RFC3339Nano)
+		updater.SetAnnotations(annotations)
+	})
+}
+
+// Pin sets the updater's pinned image, an empty image clears it.
+func Pin(ctx context.Context, c client.Client, name types.NamespacedName, image string) error {
+	return patchUpdater(ctx, c, name, func(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) {
+		updater.Spec.PinnedImage = image
+	})
+}
+
+// Rollback pins the updater to an image from its history, where 0 is the
+// most recently applied image, 1 the image before that, and so on.
+//
+// The pinned image is returned.
+func Rollback(ctx context.Context, c client.Client, name types.NamespacedName, entry int) (string, error) {
+	updater, err := loadUpdater(ctx, c, name)
+	if err != nil {
+		return "", err
+	}
+	history := updater.Status.History
+	if entry < 0 || entry >= len(history) {
+		return "", fmt.Errorf("the updater %s has %d history entries, there is no entry %d", name, len(history), entry)
+	}
+	image := history[entry].Image
+	return image, Pin(ctx, c, name, image)
+}
+
+// Suspend suspends or resumes the updater.
+func Suspend(ctx context.Context, c client.Client, name types.NamespacedName, suspend bool) error {
+	return patchUpdater(ctx, c, name, func(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) {
+		updater.Spec.Suspend = suspend
+	})
+}
+
+func patchUpdater(ctx context.Context, c client.Client, name types.NamespacedName, change func(*appsv1alpha1.ImagePolicyArgoCDUpdate)) error {
+	updater, err := loadUpdater(ctx, c, name)
+	if err != nil {
+		return err
+	}
+	patch := client.MergeFrom(updater.DeepCopy())
+	change(updater)
+	if err := c.Patch(ctx, updater, patch); err != nil {
+		return fmt.Errorf("failed to update %s: %w", name, err)
+	}
+	return nil
+}
+
+func loadUpdater(ctx context.Context, c client.Client, name types.NamespacedName) (*appsv1alpha1.ImagePolicyArgoCDUpdate, error) {
+	var updater appsv1alpha1.ImagePolicyArgoCDUpdate
+	if err := c.Get(ctx, name, &updater); err != nil {
+		return nil, fmt.Errorf("failed to load the updater %s: %w", name, err)
+	}
+	return &updater, nil
+}
+
+func status(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) string {
+	if updater.Spec.Suspend {
+		return appsv1alpha1.SuspendedReason
+	}
+	if c := appsv1alpha1.FindCondition(updater.Status.Conditions, appsv1alpha1.ReadyCondition); c != nil {
+		return c.Reason
+	}
+	return "<none>"
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
@@ -0,0 +1,335 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/imagepolicy"
+)
+
+const testImage = "bigkevmcd/go-demo:v1.1.0"
+
+var testUpdaterName = types.NamespacedName{Name: "go-demo-update", Namespace: "test-ns"}
+
+var testPolicies = imagepolicy.Reader{Version: "v1beta2"}
+
+func TestList(t *testing.T) {
+	suspended := makeUpdater(func(u *appsv1alpha1.ImagePolicyArgoCDUpdate) {
+		u.Name = "suspended-update"
+		u.Spec.Suspend = true
+	})
+	pinned := makeUpdater(func(u *appsv1alpha1.ImagePolicyArgoCDUpdate) {
+		u.Name = "pinned-update"
+		u.Spec.PinnedImage = "bigkevmcd/go-demo:v1.0.0"
+		appsv1alpha1.SetReadiness(u, corev1.ConditionTrue, appsv1alpha1.ReconciliationSucceededReason, "applied")
+	})
+	remote := makeUpdater(func(u *appsv1alpha1.ImagePolicyArgoCDUpdate) {
+		u.Name = "remote-update"
+		u.Spec.ApplicationRef.API = &appsv1alpha1.ArgoCDAPIReference{SecretRef: corev1.LocalObjectReference{Name: "argocd-api"}}
+	})
+	c := newFakeClient(t, makeUpdater(), suspended, pinned, remote, makeImagePolicy(), makeApplication())
+	var out bytes.Buffer
+
+	if err := List(context.Background(), c, testPolicies, &out, "test-ns"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `NAMESPACE  NAME              TARGET                      IMAGEPOLICY  CURRENT                   CANDIDATE                          STATUS
+test-ns    go-demo-update    Application/argocd/go-demo  go-demo      bigkevmcd/go-demo:v1.0.0  bigkevmcd/go-demo:v1.1.0           <none>
+test-ns    pinned-update     Application/argocd/go-demo  go-demo      bigkevmcd/go-demo:v1.0.0  bigkevmcd/go-demo:v1.0.0 (pinned)  ReconciliationSucceeded
+test-ns    remote-update     Application/argocd/go-demo  go-demo      <remote>                  bigkevmcd/go-demo:v1.1.0           <none>
+test-ns    suspended-update  Application/argocd/go-demo  go-demo      bigkevmcd/go-demo:v1.0.0  bigkevmcd/go-demo:v1.1.0           Suspended
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Fatalf("failed to list the updaters:\n%s", diff)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	c := newFakeClient(t, makeUpdater(), makeImagePolicy(), makeApplication())
+	var out bytes.Buffer
+
+	if err := Diff(context.Background(), c, testPolicies, &out, testUpdaterName); err != nil {
+		t.Fatal(err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"Application/argocd/go-demo source changes for image bigkevmcd/go-demo:v1.1.0:",
+		`-`, `"bigkevmcd/go-demo:v1.0.0"`,
+		`+`, `"bigkevmcd/go-demo:v1.1.0"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("diff output %q does not contain %q", got, want)
+		}
+	}
+}
+
+func TestDiffWithMultipleSources(t *testing.T) {
+	app := makeApplication()
+	spec := app.Object["spec"].(map[string]interface{})
+	spec["sources"] = []interface{}{
+		map[string]interface{}{"repoURL": "https://charts.example.com", "chart": "go-demo"},
+		spec["source"],
+	}
+	delete(spec, "source")
+	c := newFakeClient(t, makeUpdater(func(u *appsv1alpha1.ImagePolicyArgoCDUpdate) {
+		u.Spec.Source = &appsv1alpha1.SourceSelector{RepoURL: "https://github.com/bigkevmcd/go-demo.git"}
+	}), makeImagePolicy(), app)
+	var out bytes.Buffer
+
+	if err := Diff(context.Background(), c, testPolicies, &out, testUpdaterName); err != nil {
+		t.Fatal(err)
+	}
+
+	got := out.String()
+	for _, want := range []string{`-`, `"bigkevmcd/go-demo:v1.0.0"`, `+`, `"bigkevmcd/go-demo:v1.1.0"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("diff output %q does not contain %q", got, want)
+		}
+	}
+}
+
+func TestDiffWithNoChanges(t *testing.T) {
+	c := newFakeClient(t, makeUpdater(func(u *appsv1alpha1.ImagePolicyArgoCDUpdate) {
+		u.Spec.PinnedImage = "bigkevmcd/go-demo:v1.0.0"
+	}), makeApplication())
+	var out bytes.Buffer
+
+	if err := Diff(context.Background(), c, testPolicies, &out, testUpdaterName); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Application/argocd/go-demo already has image bigkevmcd/go-demo:v1.0.0\n"
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Fatalf("incorrect output:\n%s", diff)
+	}
+}
+
+func TestDiffWithBlockedImages(t *testing.T) {
+	blockedTests := []struct {
+		name    string
+		current string
+		change  func(*appsv1alpha1.ImagePolicyArgoCDUpdate)
+		want    string
+	}{
+		{
+			"filtered image", "bigkevmcd/go-demo:v1.0.0",
+			func(u *appsv1alpha1.ImagePolicyArgoCDUpdate) {
+				u.Spec.TagFilter = &appsv1alpha1.TagFilter{Exclude: "^v1.1"}
+			},
+			"Application/argocd/go-demo keeps its current image, image bigkevmcd/go-demo:v1.1.0 is not applied: " +
+				`tag "v1.1.0" matches the excluded "^v1.1"` + "\n",
+		},
+		{
+			"downgraded image", "bigkevmcd/go-demo:v1.2.0",
+			func(u *appsv1alpha1.ImagePolicyArgoCDUpdate) {
+				u.Spec.DowngradeProtection = &appsv1alpha1.DowngradeProtection{Ordering: appsv1alpha1.SemVerOrdering}
+			},
+			"Application/argocd/go-demo keeps its current image, image bigkevmcd/go-demo:v1.1.0 is not applied: " +
+				"image bigkevmcd/go-demo:v1.1.0 is older than the current image bigkevmcd/go-demo:v1.2.0 by SemVer ordering\n",
+		},
+	}
+
+	for _, tt := range blockedTests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := makeApplication()
+			if err := unstructured.SetNestedStringSlice(app.Object, []string{tt.current}, "spec", "source", "kustomize", "images"); err != nil {
+				t.Fatal(err)
+			}
+			c := newFakeClient(t, makeUpdater(tt.change), makeImagePolicy(), app)
+			var out bytes.Buffer
+
+			if err := Diff(context.Background(), c, testPolicies, &out, testUpdaterName); err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, out.String()); diff != "" {
+				t.Fatalf("incorrect output:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDiffWithRegistryChecks(t *testing.T) {
+	c := newFakeClient(t, makeUpdater(func(u *appsv1alpha1.ImagePolicyArgoCDUpdate) {
+		u.Spec.ImageCheck = &appsv1alpha1.ImageCheckSpec{}
+	}), makeImagePolicy(), makeApplication())
+	var out bytes.Buffer
+
+	if err := Diff(context.Background(), c, testPolicies, &out, testUpdaterName); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "The registry checks are not run, the controller may wait for image bigkevmcd/go-demo:v1.1.0, or apply it by digest.\n"
+	if got := out.String(); !strings.HasSuffix(got, want) {
+		t.Fatalf("diff output %q does not end with %q", got, want)
+	}
+}
+
+func TestRequestReconcile(t *testing.T) {
+	c := newFakeClient(t, makeUpdater())
+	now := time.Date(2020, time.November, 1, 10, 30, 0, 0, time.UTC)
+
+	if err := RequestReconcile(context.Background(), c, testUpdaterName, now); err != nil {
+		t.Fatal(err)
+	}
+
+	updater := loadTestUpdater(t, c)
+	if v := updater.Annotations[appsv1alpha1.ReconcileRequestedAnnotation]; v != "2020-11-01T10:30:00Z" {
+		t.Fatalf("got reconcile annotation %q, want %q", v, "2020-11-01T10:30:00Z")
+	}
+}
+
+func TestPin(t *testing.T) {
+	c := newFakeClient(t, makeUpdater())
+
+	if err := Pin(context.Background(), c, testUpdaterName, "bigkevmcd/go-demo:v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if v := loadTestUpdater(t, c).Spec.PinnedImage; v != "bigkevmcd/go-demo:v1.0.0" {
+		t.Fatalf("got pinned image %q, want %q", v, "bigkevmcd/go-demo:v1.0.0")
+	}
+
+	if err := Pin(context.Background(), c, testUpdaterName, ""); err != nil {
+		t.Fatal(err)
+	}
+	if v := loadTestUpdater(t, c).Spec.PinnedImage; v != "" {
+		t.Fatalf("got pinned image %q, want it cleared", v)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	c := newFakeClient(t, makeUpdater(func(u *appsv1alpha1.ImagePolicyArgoCDUpdate) {
+		u.Status.History = []appsv1alpha1.ImageHistoryEntry{
+			{Image: "bigkevmcd/go-demo:v1.1.0"},
+			{Image: "bigkevmcd/go-demo:v1.0.0"},
+		}
+	}))
+
+	image, err := Rollback(context.Background(), c, testUpdaterName, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if image != "bigkevmcd/go-demo:v1.0.0" {
+		t.Fatalf("got image %q, want %q", image, "bigkevmcd/go-demo:v1.0.0")
+	}
+	if v := loadTestUpdater(t, c).Spec.PinnedImage; v != "bigkevmcd/go-demo:v1.0.0" {
+		t.Fatalf("got pinned image %q, want %q", v, "bigkevmcd/go-demo:v1.0.0")
+	}
+}
+
+func TestRollbackWithoutHistory(t *testing.T) {
+	c := newFakeClient(t, makeUpdater())
+
+	_, err := Rollback(context.Background(), c, testUpdaterName, 1)
+
+	want := "the updater test-ns/go-demo-update has 0 history entries, there is no entry 1"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+	if v := loadTestUpdater(t, c).Spec.PinnedImage; v != "" {
+		t.Fatalf("got pinned image %q, want none", v)
+	}
+}
+
+func TestSuspend(t *testing.T) {
+	c := newFakeClient(t, makeUpdater())
+
+	if err := Suspend(context.Background(), c, testUpdaterName, true); err != nil {
+		t.Fatal(err)
+	}
+	if !loadTestUpdater(t, c).Spec.Suspend {
+		t.Fatal("the updater was not suspended")
+	}
+
+	if err := Suspend(context.Background(), c, testUpdaterName, false); err != nil {
+		t.Fatal(err)
+	}
+	if loadTestUpdater(t, c).Spec.Suspend {
+		t.Fatal("the updater was not resumed")
+	}
+}
+
+func TestPinMissingUpdater(t *testing.T) {
+	c := newFakeClient(t)
+
+	err := Pin(context.Background(), c, testUpdaterName, testImage)
+
+	if err == nil || !strings.Contains(err.Error(), "failed to load the updater test-ns/go-demo-update") {
+		t.Fatalf("got error %v, want a missing updater error", err)
+	}
+}
+
+func newFakeClient(t *testing.T, objs ...runtime.Object) client.Client {
+	t.Helper()
+	scheme, err := NewScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewFakeClientWithScheme(scheme, objs...)
+}
+
+func loadTestUpdater(t *testing.T, c client.Client) *appsv1alpha1.ImagePolicyArgoCDUpdate {
+	t.Helper()
+	var updater appsv1alpha1.ImagePolicyArgoCDUpdate
+	if err := c.Get(context.Background(), testUpdaterName, &updater); err != nil {
+		t.Fatal(err)
+	}
+	return &updater
+}
+
+func makeUpdater(opts ...func(*appsv1alpha1.ImagePolicyArgoCDUpdate)) *appsv1alpha1.ImagePolicyArgoCDUpdate {
+	u := &appsv1alpha1.ImagePolicyArgoCDUpdate{
+		ObjectMeta: metav1.ObjectMeta{Name: testUpdaterName.Name, Namespace: testUpdaterName.Namespace},
+		Spec: appsv1alpha1.ImagePolicyArgoCDUpdateSpec{
+			ApplicationRef: &appsv1alpha1.ApplicationReference{Name: "go-demo", Namespace: "argocd"},
+			ImagePolicyRef: corev1.LocalObjectReference{Name: "go-demo"},
+		},
+	}
+	for _, o := range opts {
+		o(u)
+	}
+	return u
+}
+
+func makeImagePolicy() *unstructured.Unstructured {
+	policy := testPolicies.NewObject()
+	policy.SetName("go-demo")
+	policy.SetNamespace("test-ns")
+	policy.Object["status"] = map[string]interface{}{
+		"latestRef": map[string]interface{}{"name": "bigkevmcd/go-demo", "tag": "v1.1.0"},
+	}
+	return policy
+}
+
+func makeApplication() *unstructured.Unstructured {
+	app := application.NewObject()
+	app.SetName("go-demo")
+	app.SetNamespace("argocd")
+	app.Object["spec"] = map[string]interface{}{
+		"source": map[string]interface{}{
+			"repoURL": "https://github.com/bigkevmcd/go-demo.git",
+			"path":    "examples/kustomize/overlays/dev",
+			"kustomize": map[string]interface{}{
+				"images": []interface{}{"bigkevmcd/go-demo:v1.0.0"},
+			},
+		},
+	}
+	return app
+}
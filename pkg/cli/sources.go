@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"context"
+	"errors"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/imagepolicy"
+)
+
+// errRemoteTarget is returned for updaters whose Application is accessed
+// through a kubeconfig or the ArgoCD API, rather than the current cluster.
+var errRemoteTarget = errors.New("the Application is not in the current cluster")
+
+// loadSource loads the ApplicationSource that the updater writes to.
+func loadSource(ctx context.Context, c client.Client, updater *appsv1alpha1.ImagePolicyArgoCDUpdate) (*application.ApplicationSource, error) {
+	spec := updater.Spec
+	var app *application.Application
+	switch {
+	case spec.ApplicationRef != nil:
+		if spec.ApplicationRef.KubeConfig != nil || spec.ApplicationRef.API != nil {
+			return nil, errRemoteTarget
+		}
+		obj := application.NewObject()
+		if err := c.Get(ctx, types.NamespacedName{Name: spec.ApplicationRef.Name, Namespace: spec.ApplicationRef.Namespace}, obj); err != nil {
+			return nil, err
+		}
+		loaded, err := application.New(obj)
+		if err != nil {
+			return nil, err
+		}
+		app = loaded
+	case spec.ApplicationSetRef != nil:
+		obj := application.NewApplicationSetObject()
+		if err := c.Get(ctx, types.NamespacedName{Name: spec.ApplicationSetRef.Name, Namespace: spec.ApplicationSetRef.Namespace}, obj); err != nil {
+			return nil, err
+		}
+		loaded, err := application.NewTemplate(obj)
+		if err != nil {
+			return nil, err
+		}
+		app = loaded
+	default:
+		return nil, errors.New("the updater has no applicationRef or applicationSetRef")
+	}
+	i, err := app.Spec.SelectSource(spec.Source)
+	if err != nil {
+		return nil, err
+	}
+	return app.Spec.AllSources()[i], nil
+}
+
+// targetName describes the updater's target.
+func targetName(updater *appsv1alpha1.ImagePolicyArgoCDUpdate) string {
+	if name := appsv1alpha1.TargetName(updater); name != "" {
+		return name
+	}
+	return "<none>"
+}
+
+func loadImagePolicy(ctx context.Context, c client.Client, policies imagepolicy.Reader, updater *appsv1alpha1.ImagePolicyArgoCDUpdate) (*imagepolicy.ImagePolicy, error) {
+	return policies.Get(ctx, c, types.NamespacedName{Name: updater.Spec.ImagePolicyRef.Name, Namespace: updater.Namespace})
+}
@@ -0,0 +1,269 @@
+// Package config loads and validates the manager's configuration file.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/time/rate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// APIVersion is the version of the configuration file format.
+	APIVersion = "config.apps.bigkevmcd.com/v1alpha1"
+	// Kind is the kind of the configuration file.
+	Kind = "ManagerConfig"
+)
+
+// Log levels for the manager.
+const (
+	DebugLevel = "debug"
+	InfoLevel  = "info"
+	ErrorLevel = "error"
+)
+
+// ManagerConfig configures the manager and the controller.
+type ManagerConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// MetricsBindAddress is the address the metrics endpoint binds to.
+	MetricsBindAddress string `json:"metricsBindAddress,omitempty"`
+
+	// HealthProbeBindAddress is the address the /healthz and /readyz
+	// endpoints bind to, they are disabled if this is empty.
+	HealthProbeBindAddress string `json:"healthProbeBindAddress,omitempty"`
+
+	// LeaderElection ensures there is only one active manager.
+	LeaderElection bool `json:"leaderElection,omitempty"`
+
+	// WatchNamespaces restricts the namespaces that updaters are reconciled
+	// in, all namespaces are reconciled if this is empty.
+	WatchNamespaces []string `json:"watchNamespaces,omitempty"`
+
+	// ApplicationNamespaces restricts the namespaces of the Applications that
+	// can be updated, it defaults to the WatchNamespaces.
+	ApplicationNamespaces []string `json:"applicationNamespaces,omitempty"`
+
+	// LogLevel is one of "debug", "info" or "error".
+	LogLevel string `json:"logLevel,omitempty"`
+
+	Controller ControllerConfig `json:"controller,omitempty"`
+	Client     ClientConfig     `json:"client,omitempty"`
+}
+
+// ControllerConfig configures how updaters are reconciled.
+type ControllerConfig struct {
+	// MaxConcurrentReconciles is the number of update targets that can be
+	// reconciled at the same time.
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles,omitempty"`
+
+	// SyncPeriod is how often the cached resources are resynced, which
+	// reconciles all the updaters.
+	SyncPeriod metav1.Duration `json:"syncPeriod,omitempty"`
+
+	// RequeueInterval is how long after a successful reconcile an update
+	// target is reconciled again, zero disables this.
+	RequeueInterval metav1.Duration `json:"requeueInterval,omitempty"`
+
+	RateLimiter RateLimiterConfig `json:"rateLimiter,omitempty"`
+}
+
+// RateLimiterConfig configures the backoff for failed reconciles.
+//
+// Failures for each update target are retried with an exponential backoff
+// from BaseDelay to MaxDelay, and the retries for all targets are limited to
+// QPS, with bursts of up to Burst.
+type RateLimiterConfig struct {
+	BaseDelay metav1.Duration `json:"baseDelay,omitempty"`
+	MaxDelay  metav1.Duration `json:"maxDelay,omitempty"`
+	QPS       float64         `json:"qps,omitempty"`
+	Burst     int             `json:"burst,omitempty"`
+}
+
+// ClientConfig configures the rate limits of the Kubernetes API client.
+type ClientConfig struct {
+	QPS   float32 `json:"qps,omitempty"`
+	Burst int     `json:"burst,omitempty"`
+}
+
+// Default returns the configuration that is used without a configuration
+// file, the values in a file replace these.
+func Default() *ManagerConfig {
+	return &ManagerConfig{
+		TypeMeta:           metav1.TypeMeta{APIVersion: APIVersion, Kind: Kind},
+		MetricsBindAddress: ":8080",
+		LogLevel:           DebugLevel,
+		Controller: ControllerConfig{
+			MaxConcurrentReconciles: 1,
+			SyncPeriod:              metav1.Duration{Duration: 10 * time.Hour},
+			// These are the values of the workqueue's default controller rate
+			// limiter.
+			RateLimiter: RateLimiterConfig{
+				BaseDelay: metav1.Duration{Duration: 5 * time.Millisecond},
+				MaxDelay:  metav1.Duration{Duration: 1000 * time.Second},
+				QPS:       10,
+				Burst:     100,
+			},
+		},
+		Client: ClientConfig{QPS: 20, Burst: 30},
+	}
+}
+
+// Load reads the configuration file, and validates it.
+func Load(path string) (*ManagerConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the configuration file: %w", err)
+	}
+	return Parse(b)
+}
+
+// Parse parses the configuration, and validates it.
+//
+// Fields that are not set keep their default values.
+func Parse(b []byte) (*ManagerConfig, error) {
+	cfg := Default()
+	cfg.TypeMeta = metav1.TypeMeta{}
+	if err := yaml.UnmarshalStrict(b, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse the configuration file: %w", err)
+	}
+	if cfg.APIVersion != APIVersion || cfg.Kind != Kind {
+		return nil, fmt.Errorf("the configuration file has apiVersion %q and kind %q, want %q and %q", cfg.APIVersion, cfg.Kind, APIVersion, Kind)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate returns an error describing all the invalid fields.
+func (c *ManagerConfig) Validate() error {
+	var errs field.ErrorList
+	switch c.LogLevel {
+	case DebugLevel, InfoLevel, ErrorLevel:
+	default:
+		errs = append(errs, field.NotSupported(field.NewPath("logLevel"), c.LogLevel, []string{DebugLevel, InfoLevel, ErrorLevel}))
+	}
+	errs = append(errs, validateNamespaces(field.NewPath("watchNamespaces"), c.WatchNamespaces)...)
+	errs = append(errs, validateNamespaces(field.NewPath("applicationNamespaces"), c.ApplicationNamespaces)...)
+
+	controller := field.NewPath("controller")
+	if c.Controller.MaxConcurrentReconciles < 1 {
+		errs = append(errs, field.Invalid(controller.Child("maxConcurrentReconciles"), c.Controller.MaxConcurrentReconciles, "must be at least 1"))
+	}
+	if c.Controller.SyncPeriod.Duration <= 0 {
+		errs = append(errs, field.Invalid(controller.Child("syncPeriod"), c.Controller.SyncPeriod.Duration.String(), "must be greater than zero"))
+	}
+	if c.Controller.RequeueInterval.Duration < 0 {
+		errs = append(errs, field.Invalid(controller.Child("requeueInterval"), c.Controller.RequeueInterval.Duration.String(), "must not be negative"))
+	}
+
+	rl, rlPath := c.Controller.RateLimiter, controller.Child("rateLimiter")
+	if rl.BaseDelay.Duration <= 0 {
+		errs = append(errs, field.Invalid(rlPath.Child("baseDelay"), rl.BaseDelay.Duration.String(), "must be greater than zero"))
+	}
+	if rl.MaxDelay.Duration < rl.BaseDelay.Duration {
+		errs = append(errs, field.Invalid(rlPath.Child("maxDelay"), rl.MaxDelay.Duration.String(), "must not be less than the baseDelay"))
+	}
+	if rl.QPS <= 0 {
+		errs = append(errs, field.Invalid(rlPath.Child("qps"), rl.QPS, "must be greater than zero"))
+	}
+	if rl.Burst < 1 {
+		errs = append(errs, field.Invalid(rlPath.Child("burst"), rl.Burst, "must be at least 1"))
+	}
+
+	client := field.NewPath("client")
+	if c.Client.QPS <= 0 {
+		errs = append(errs, field.Invalid(client.Child("qps"), c.Client.QPS, "must be greater than zero"))
+	}
+	if c.Client.Burst < 1 {
+		errs = append(errs, field.Invalid(client.Child("burst"), c.Client.Burst, "must be at least 1"))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errs.ToAggregate())
+	}
+	return nil
+}
+
+// ZapLevel returns the zap logging level for the LogLevel.
+func (c *ManagerConfig) ZapLevel() zapcore.Level {
+	switch c.LogLevel {
+	case InfoLevel:
+		return zapcore.InfoLevel
+	case ErrorLevel:
+		return zapcore.ErrorLevel
+	}
+	return zapcore.DebugLevel
+}
+
+// AllowedApplicationNamespaces returns the ApplicationNamespaces, or the
+// WatchNamespaces if there are none.
+func (c *ManagerConfig) AllowedApplicationNamespaces() []string {
+	if len(c.ApplicationNamespaces) == 0 {
+		return c.WatchNamespaces
+	}
+	return c.ApplicationNamespaces
+}
+
+// CacheNamespaces returns the namespaces that the manager's cache is
+// restricted to, the watched namespaces and the namespaces of the
+// Applications, so that the manager only needs Role permissions in those
+// namespaces.
+//
+// If this is empty, all namespaces are cached.
+func (c *ManagerConfig) CacheNamespaces() []string {
+	if len(c.WatchNamespaces) == 0 {
+		return nil
+	}
+	return MergeNamespaces(c.WatchNamespaces, c.AllowedApplicationNamespaces())
+}
+
+// SplitNamespaces splits a comma-separated list of namespaces.
+func SplitNamespaces(s string) []string {
+	var namespaces []string
+	for _, ns := range strings.Split(s, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// MergeNamespaces returns the namespaces in either list, without duplicates.
+func MergeNamespaces(a, b []string) []string {
+	seen := map[string]bool{}
+	var merged []string
+	for _, ns := range append(append([]string{}, a...), b...) {
+		if !seen[ns] {
+			seen[ns] = true
+			merged = append(merged, ns)
+		}
+	}
+	return merged
+}
+
+// RateLimiter returns a workqueue rate limiter with the configured backoff.
+func (c RateLimiterConfig) RateLimiter() ratelimiter.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(c.BaseDelay.Duration, c.MaxDelay.Duration),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(c.QPS), c.Burst)},
+	)
+}
+
+func validateNamespaces(path *field.Path, namespaces []string) field.ErrorList {
+	var errs field.ErrorList
+	for i, ns := range namespaces {
+		if ns == "" {
+			errs = append(errs, field.Required(path.Index(i), "namespaces must not be empty"))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(`
+apiVersion: config.apps.bigkevmcd.com/v1alpha1
+kind: ManagerConfig
+healthProbeBindAddress: ":8081"
+leaderElection: true
+watchNamespaces:
+- team-a
+- team-b
+logLevel: info
+controller:
+  maxConcurrentReconciles: 4
+  requeueInterval: 10m
+  rateLimiter:
+    baseDelay: 1s
+    maxDelay: 5m
+client:
+  qps: 50
+  burst: 100
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Default()
+	want.HealthProbeBindAddress = ":8081"
+	want.LeaderElection = true
+	want.WatchNamespaces = []string{"team-a", "team-b"}
+	want.LogLevel = InfoLevel
+	want.Controller.MaxConcurrentReconciles = 4
+	want.Controller.RequeueInterval = metav1.Duration{Duration: 10 * time.Minute}
+	want.Controller.RateLimiter.BaseDelay = metav1.Duration{Duration: time.Second}
+	want.Controller.RateLimiter.MaxDelay = metav1.Duration{Duration: 5 * time.Minute}
+	want.Client = ClientConfig{QPS: 50, Burst: 100}
+	if diff := cmp.Diff(want, cfg); diff != "" {
+		t.Fatalf("failed to parse the configuration:\n%s", diff)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	parseTests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			"missing kind",
+			"apiVersion: config.apps.bigkevmcd.com/v1alpha1\n",
+			`the configuration file has apiVersion "config.apps.bigkevmcd.com/v1alpha1" and kind "", want "config.apps.bigkevmcd.com/v1alpha1" and "ManagerConfig"`,
+		},
+		{
+			"unknown field",
+			"apiVersion: config.apps.bigkevmcd.com/v1alpha1\nkind: ManagerConfig\nworkers: 2\n",
+			`failed to parse the configuration file: .*unknown field "workers"`,
+		},
+		{
+			"invalid values",
+			`
+apiVersion: config.apps.bigkevmcd.com/v1alpha1
+kind: ManagerConfig
+logLevel: trace
+controller:
+  maxConcurrentReconciles: 0
+  rateLimiter:
+    baseDelay: 1m
+    maxDelay: 1s
+`,
+			`invalid configuration: \[logLevel: Unsupported value: "trace": supported values: "debug", "info", "error", controller.maxConcurrentReconciles: Invalid value: 0: must be at least 1, controller.rateLimiter.maxDelay: Invalid value: "1s": must not be less than the baseDelay\]`,
+		},
+		{
+			"empty namespace",
+			"apiVersion: config.apps.bigkevmcd.com/v1alpha1\nkind: ManagerConfig\nwatchNamespaces: [\"\"]\n",
+			`invalid configuration: watchNamespaces\[0\]: Required value: namespaces must not be empty`,
+		},
+	}
+
+	for _, tt := range parseTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.config))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !regexp.MustCompile("^" + tt.wantErr + "$").MatchString(err.Error()) {
+				t.Fatalf("got error %q, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(path, []byte("apiVersion: config.apps.bigkevmcd.com/v1alpha1\nkind: ManagerConfig\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(Default(), cfg); diff != "" {
+		t.Fatalf("failed to load the configuration:\n%s", diff)
+	}
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	namespaceTests := []struct {
+		name                string
+		watch               []string
+		applications        []string
+		wantApplications    []string
+		wantCacheNamespaces []string
+	}{
+		{"all namespaces", nil, nil, nil, nil},
+		{"watched namespaces", []string{"team-a", "team-b"}, nil, []string{"team-a", "team-b"}, []string{"team-a", "team-b"}},
+		{"application namespaces", []string{"team-a", "team-b"}, []string{"argocd", "team-a"}, []string{"argocd", "team-a"}, []string{"team-a", "team-b", "argocd"}},
+		{"application namespaces with all watched", nil, []string{"argocd"}, []string{"argocd"}, nil},
+	}
+
+	for _, tt := range namespaceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.WatchNamespaces = tt.watch
+			cfg.ApplicationNamespaces = tt.applications
+
+			if diff := cmp.Diff(tt.wantApplications, cfg.AllowedApplicationNamespaces()); diff != "" {
+				t.Errorf("got the wrong application namespaces:\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantCacheNamespaces, cfg.CacheNamespaces()); diff != "" {
+				t.Errorf("got the wrong cache namespaces:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSplitNamespaces(t *testing.T) {
+	splitTests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"team-a", []string{"team-a"}},
+		{" team-a, team-b ,,", []string{"team-a", "team-b"}},
+	}
+
+	for _, tt := range splitTests {
+		if diff := cmp.Diff(tt.want, SplitNamespaces(tt.value)); diff != "" {
+			t.Errorf("failed to split %q:\n%s", tt.value, diff)
+		}
+	}
+}
+
+func TestMergeNamespaces(t *testing.T) {
+	merged := MergeNamespaces([]string{"team-a", "team-b"}, []string{"argocd", "team-b", "argocd"})
+
+	if diff := cmp.Diff([]string{"team-a", "team-b", "argocd"}, merged); diff != "" {
+		t.Fatalf("failed to merge the namespaces:\n%s", diff)
+	}
+}
@@ -0,0 +1,179 @@
+// Package cosign verifies cosign signatures of images stored in an OCI
+// registry.
+package cosign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/registry"
+)
+
+// SignatureAnnotation is the layer annotation that holds the base64 encoded
+// signature of the layer's payload.
+const SignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+// SignatureType is the type of the payload that cosign signs for an image.
+const SignatureType = "cosign container image signature"
+
+// VerificationError is returned when the image has no signature that can be
+// verified with the public keys.
+type VerificationError struct {
+	Image  string
+	Reason string
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("failed to verify the signature of %s: %s", e.Image, e.Reason)
+}
+
+// ParsePublicKeys parses the PEM encoded ECDSA public keys in the values,
+// a value can contain more than one key.
+func ParsePublicKeys(data map[string][]byte) ([]*ecdsa.PublicKey, error) {
+	keys := []*ecdsa.PublicKey{}
+	names := make([]string, 0, len(data))
+	for k := range data {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rest := data[name]
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the public key in %q: %w", name, err)
+			}
+			key, ok := pub.(*ecdsa.PublicKey)
+			if !ok {
+				return nil, fmt.Errorf("the public key in %q is not an ECDSA key", name)
+			}
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no public keys found")
+	}
+	return keys, nil
+}
+
+// SignatureTag returns the tag that cosign stores the signature of the image
+// with the digest in.
+func SignatureTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".sig"
+}
+
+// Verify checks that the image with the reference has a cosign signature that
+// can be verified with one of the keys, and that the signed payload is an
+// image signature for the image's digest. It returns the verified digest.
+//
+// If there is no valid signature, the error is a *VerificationError, other
+// errors come from accessing the registry.
+func Verify(ctx context.Context, client *registry.Client, ref registry.Reference, keys []*ecdsa.PublicKey) (string, error) {
+	image := ref.String()
+	digest := ref.Digest
+	if digest == "" {
+		desc, err := client.Head(ctx, ref)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve the digest of %s: %w", image, err)
+		}
+		digest = desc.Digest
+	}
+
+	sigRef := ref.WithTag(SignatureTag(digest))
+	body, _, err := client.Manifest(ctx, sigRef)
+	if errors.Is(err, registry.ErrNotFound) {
+		return "", &VerificationError{Image: image, Reason: "no signature found"}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch the signature of %s: %w", image, err)
+	}
+	var m signatureManifest
+	if err := json.Unmarshal(body, &m); err != nil {
+		return "", &VerificationError{Image: image, Reason: fmt.Sprintf("failed to parse the signature manifest: %s", err)}
+	}
+
+	reason := "no signature matches the public keys"
+	for _, layer := range m.Layers {
+		encoded, ok := layer.Annotations[SignatureAnnotation]
+		if !ok {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		payload, err := client.Blob(ctx, sigRef, layer.Digest)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch the signature payload of %s: %w", image, err)
+		}
+		if !verifySignature(keys, payload, sig) {
+			continue
+		}
+		signed, err := signedDigest(payload)
+		if err != nil {
+			reason = err.Error()
+			continue
+		}
+		if signed != digest {
+			reason = fmt.Sprintf("the signature is for digest %s, not %s", signed, digest)
+			continue
+		}
+		return digest, nil
+	}
+	return "", &VerificationError{Image: image, Reason: reason}
+}
+
+type signatureManifest struct {
+	Layers []struct {
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+// simpleSigningPayload is the payload that cosign signs.
+type simpleSigningPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+func verifySignature(keys []*ecdsa.PublicKey, payload, sig []byte) bool {
+	h := sha256.Sum256(payload)
+	for _, key := range keys {
+		if ecdsa.VerifyASN1(key, h[:], sig) {
+			return true
+		}
+	}
+	return false
+}
+
+func signedDigest(payload []byte) (string, error) {
+	var p simpleSigningPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return "", fmt.Errorf("failed to parse the signed payload: %w", err)
+	}
+	if p.Critical.Type != SignatureType {
+		return "", fmt.Errorf("the signed payload has type %q, not %q", p.Critical.Type, SignatureType)
+	}
+	if p.Critical.Image.DockerManifestDigest == "" {
+		return "", errors.New("the signed payload has no image digest")
+	}
+	return p.Critical.Image.DockerManifestDigest, nil
+}
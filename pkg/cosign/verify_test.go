@@ -0,0 +1,181 @@
+package cosign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/registry"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/registry/registrytest"
+)
+
+const testRepository = "bigkevmcd/go-demo"
+
+var testManifest = []byte(`{"schemaVersion":2}`)
+
+func TestVerify(t *testing.T) {
+	reg := registrytest.NewServer(t)
+	key := generateKey(t)
+	digest := reg.AddManifest(testRepository, "v1.2.3", "application/vnd.oci.image.manifest.v1+json", testManifest)
+	sign(t, reg, key, digest, digest)
+	ref := registry.Reference{Registry: reg.Host(), Repository: testRepository, Tag: "v1.2.3"}
+
+	verified, err := Verify(context.Background(), registry.NewClient(reg.Client()), ref, []*ecdsa.PublicKey{&key.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if verified != digest {
+		t.Fatalf("got digest %s, want %s", verified, digest)
+	}
+}
+
+func TestVerifyWithCredentials(t *testing.T) {
+	reg := registrytest.NewServer(t)
+	reg.RequireCredentials("testuser", "testpass")
+	key := generateKey(t)
+	digest := reg.AddManifest(testRepository, "v1.2.3", "application/vnd.oci.image.manifest.v1+json", testManifest)
+	sign(t, reg, key, digest, digest)
+	ref := registry.Reference{Registry: reg.Host(), Repository: testRepository, Digest: digest}
+	client := registry.NewClient(reg.Client()).WithCredentials(registry.Credentials{reg.Host(): {Username: "testuser", Password: "testpass"}})
+
+	verified, err := Verify(context.Background(), client, ref, []*ecdsa.PublicKey{&key.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if verified != digest {
+		t.Fatalf("got digest %s, want %s", verified, digest)
+	}
+}
+
+func TestVerifyFailures(t *testing.T) {
+	key := generateKey(t)
+	otherKey := generateKey(t)
+
+	verifyTests := []struct {
+		desc       string
+		signWith   *ecdsa.PrivateKey
+		signDigest string
+		signType   string
+		wantReason string
+	}{
+		{"unsigned", nil, "", "", "no signature found"},
+		{"signed with another key", otherKey, "", "", "no signature matches the public keys"},
+		{"signature for another image", key, "sha256:1234", "", "the signature is for digest sha256:1234, not " + registrytest.Digest(testManifest)},
+		{"signature of another type", key, "", "cosign attestation", `the signed payload has type "cosign attestation", not "cosign container image signature"`},
+	}
+
+	for _, tt := range verifyTests {
+		t.Run(tt.desc, func(t *testing.T) {
+			reg := registrytest.NewServer(t)
+			digest := reg.AddManifest(testRepository, "v1.2.3", "application/vnd.oci.image.manifest.v1+json", testManifest)
+			if tt.signWith != nil {
+				signed := digest
+				if tt.signDigest != "" {
+					signed = tt.signDigest
+				}
+				signType := SignatureType
+				if tt.signType != "" {
+					signType = tt.signType
+				}
+				signPayload(t, reg, tt.signWith, digest, signed, signType)
+			}
+			ref := registry.Reference{Registry: reg.Host(), Repository: testRepository, Tag: "v1.2.3"}
+
+			_, err := Verify(context.Background(), registry.NewClient(reg.Client()), ref, []*ecdsa.PublicKey{&key.PublicKey})
+
+			var verr *VerificationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("got error %v, want a VerificationError", err)
+			}
+			if verr.Reason != tt.wantReason {
+				t.Fatalf("got reason %q, want %q", verr.Reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestParsePublicKeys(t *testing.T) {
+	key1, key2 := generateKey(t), generateKey(t)
+
+	keys, err := ParsePublicKeys(map[string][]byte{
+		"cosign.pub": append(encodePublicKey(t, key1), encodePublicKey(t, key2)...),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 2 || !keys[0].Equal(&key1.PublicKey) || !keys[1].Equal(&key2.PublicKey) {
+		t.Fatalf("failed to parse the keys, got %d keys", len(keys))
+	}
+}
+
+func TestParsePublicKeysWithNoKeys(t *testing.T) {
+	_, err := ParsePublicKeys(map[string][]byte{"cosign.pub": []byte("not a key")})
+
+	if err == nil || err.Error() != "no public keys found" {
+		t.Fatalf("got error %v, want no public keys", err)
+	}
+}
+
+func generateKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encodePublicKey(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// sign stores a cosign signature of the signed digest, for the image with the
+// digest, in the registry.
+func sign(t *testing.T, reg *registrytest.Server, key *ecdsa.PrivateKey, digest, signed string) {
+	t.Helper()
+	signPayload(t, reg, key, digest, signed, SignatureType)
+}
+
+// signPayload stores a cosign signature of a payload with the type.
+func signPayload(t *testing.T, reg *registrytest.Server, key *ecdsa.PrivateKey, digest, signed, payloadType string) {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"%s/%s"},"image":{"docker-manifest-digest":"%s"},"type":"%s"},"optional":null}`, reg.Host(), testRepository, signed, payloadType))
+	h := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"layers": []map[string]interface{}{
+			{
+				"mediaType":   "application/vnd.dev.cosign.simplesigning.v1+json",
+				"digest":      reg.AddBlob(payload),
+				"size":        len(payload),
+				"annotations": map[string]string{SignatureAnnotation: base64.StdEncoding.EncodeToString(sig)},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.AddManifest(testRepository, SignatureTag(digest), "application/vnd.oci.image.manifest.v1+json", manifest)
+}
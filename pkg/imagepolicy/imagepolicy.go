@@ -0,0 +1,154 @@
+// Package imagepolicy reads Flux ImagePolicies, independently of the API
+// version that the cluster serves.
+package imagepolicy
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/apiwait"
+)
+
+const (
+	// Group is the API group of ImagePolicies.
+	Group = "image.toolkit.fluxcd.io"
+	// Kind is the kind of ImagePolicies.
+	Kind = "ImagePolicy"
+)
+
+// Resource is the ImagePolicy resource, in any version.
+var Resource = schema.GroupVersionResource{Group: Group, Resource: "imagepolicies"}
+
+// SupportedVersions are the ImagePolicy versions that can be read, the most
+// preferred first.
+var SupportedVersions = []string{"v1beta2", "v1beta1", "v1alpha2", "v1alpha1"}
+
+// ImagePolicy is the version-independent view of a Flux ImagePolicy.
+type ImagePolicy struct {
+	Name       string
+	Namespace  string
+	Generation int64
+
+	// LatestImage is the image that the policy selected, it is empty if the
+	// policy hasn't selected one.
+	LatestImage string
+
+	// Policy is the kind of policy that orders the tags, it is empty if the
+	// policy is not one of the PolicyKinds.
+	Policy PolicyKind
+
+	// Descending is true if the policy's order is "desc", so that it selects
+	// the lowest tag rather than the highest.
+	Descending bool
+}
+
+// PolicyKind is the field in spec.policy that configures how an ImagePolicy
+// orders tags.
+type PolicyKind string
+
+const (
+	// SemVerPolicy orders tags as semantic versions.
+	SemVerPolicy PolicyKind = "semver"
+	// AlphabeticalPolicy orders tags lexically.
+	AlphabeticalPolicy PolicyKind = "alphabetical"
+	// NumericalPolicy orders tags as numbers, it was added in v1beta1.
+	NumericalPolicy PolicyKind = "numerical"
+)
+
+// policyKinds are the PolicyKinds in the order that they're read.
+var policyKinds = []PolicyKind{SemVerPolicy, AlphabeticalPolicy, NumericalPolicy}
+
+// Reader reads ImagePolicies in an API version.
+type Reader struct {
+	Version string
+}
+
+// GroupVersionKind returns the ImagePolicy kind in the Reader's version.
+func (r Reader) GroupVersionKind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: Group, Version: r.Version, Kind: Kind}
+}
+
+// NewObject returns an empty ImagePolicy in the Reader's version, for
+// watching and reading ImagePolicies.
+func (r Reader) NewObject() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(r.GroupVersionKind())
+	return u
+}
+
+// Get reads the named ImagePolicy.
+func (r Reader) Get(ctx context.Context, c client.Reader, name types.NamespacedName) (*ImagePolicy, error) {
+	u := r.NewObject()
+	if err := c.Get(ctx, name, u); err != nil {
+		return nil, err
+	}
+	return FromUnstructured(u)
+}
+
+// FromUnstructured reads an ImagePolicy in any of the SupportedVersions.
+//
+// The latest image is read from status.latestRef if it's set, which replaced
+// status.latestImage in v1beta2, or status.latestImage.
+func FromUnstructured(u *unstructured.Unstructured) (*ImagePolicy, error) {
+	policy := &ImagePolicy{
+		Name:       u.GetName(),
+		Namespace:  u.GetNamespace(),
+		Generation: u.GetGeneration(),
+	}
+	ref, ok, err := unstructured.NestedStringMap(u.Object, "status", "latestRef")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status.latestRef of ImagePolicy %s/%s: %w", u.GetNamespace(), u.GetName(), err)
+	}
+	if ok && ref["name"] != "" {
+		policy.LatestImage = ref["name"]
+		if ref["tag"] != "" {
+			policy.LatestImage += ":" + ref["tag"]
+		}
+		if ref["digest"] != "" {
+			policy.LatestImage += "@" + ref["digest"]
+		}
+	} else {
+		latest, _, err := unstructured.NestedString(u.Object, "status", "latestImage")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read status.latestImage of ImagePolicy %s/%s: %w", u.GetNamespace(), u.GetName(), err)
+		}
+		policy.LatestImage = latest
+	}
+	for _, kind := range policyKinds {
+		fields, ok, err := unstructured.NestedMap(u.Object, "spec", "policy", string(kind))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spec.policy.%s of ImagePolicy %s/%s: %w", kind, u.GetNamespace(), u.GetName(), err)
+		}
+		if !ok {
+			continue
+		}
+		policy.Policy = kind
+		// The semver policy has no order, and the order defaults to "asc".
+		order, _ := fields["order"].(string)
+		policy.Descending = order == "desc"
+		break
+	}
+	return policy, nil
+}
+
+// ServedVersion returns the most preferred of the SupportedVersions that the
+// API server serves.
+func ServedVersion(d discovery.DiscoveryInterface) (string, error) {
+	for _, version := range SupportedVersions {
+		gvr := Resource.GroupResource().WithVersion(version)
+		missing, err := apiwait.Missing(d, []schema.GroupVersionResource{gvr})
+		if err != nil {
+			return "", fmt.Errorf("failed to discover the ImagePolicy versions: %w", err)
+		}
+		if len(missing) == 0 {
+			return version, nil
+		}
+	}
+	return "", fmt.Errorf("the API server serves none of the supported ImagePolicy versions %v", SupportedVersions)
+}
@@ -0,0 +1,186 @@
+package imagepolicy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestFromUnstructured(t *testing.T) {
+	policyTests := []struct {
+		name   string
+		policy *unstructured.Unstructured
+		want   *ImagePolicy
+	}{
+		{
+			"v1alpha1 with a semver policy",
+			makePolicy("v1alpha1", map[string]interface{}{
+				"spec":   map[string]interface{}{"policy": map[string]interface{}{"semver": map[string]interface{}{"range": ">=1.0.0"}}},
+				"status": map[string]interface{}{"latestImage": "bigkevmcd/go-demo:v1.1.0"},
+			}),
+			&ImagePolicy{Name: "go-demo", Namespace: "test-ns", Generation: 2, LatestImage: "bigkevmcd/go-demo:v1.1.0", Policy: SemVerPolicy},
+		},
+		{
+			"v1beta1 with an alphabetical policy",
+			makePolicy("v1beta1", map[string]interface{}{
+				"spec":   map[string]interface{}{"policy": map[string]interface{}{"alphabetical": map[string]interface{}{"order": "asc"}}},
+				"status": map[string]interface{}{"latestImage": "bigkevmcd/go-demo:main-af93dae"},
+			}),
+			&ImagePolicy{Name: "go-demo", Namespace: "test-ns", Generation: 2, LatestImage: "bigkevmcd/go-demo:main-af93dae", Policy: AlphabeticalPolicy},
+		},
+		{
+			"v1beta2 with a latestRef",
+			makePolicy("v1beta2", map[string]interface{}{
+				"status": map[string]interface{}{"latestRef": map[string]interface{}{"name": "bigkevmcd/go-demo", "tag": "v1.1.0"}},
+			}),
+			&ImagePolicy{Name: "go-demo", Namespace: "test-ns", Generation: 2, LatestImage: "bigkevmcd/go-demo:v1.1.0"},
+		},
+		{
+			"v1beta2 with a latestRef digest",
+			makePolicy("v1beta2", map[string]interface{}{
+				"status": map[string]interface{}{"latestRef": map[string]interface{}{"name": "bigkevmcd/go-demo", "tag": "v1.1.0", "digest": "sha256:abc123"}},
+			}),
+			&ImagePolicy{Name: "go-demo", Namespace: "test-ns", Generation: 2, LatestImage: "bigkevmcd/go-demo:v1.1.0@sha256:abc123"},
+		},
+		{
+			"no latest image",
+			makePolicy("v1beta2", map[string]interface{}{}),
+			&ImagePolicy{Name: "go-demo", Namespace: "test-ns", Generation: 2},
+		},
+	}
+
+	for _, tt := range policyTests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := FromUnstructured(tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, policy); diff != "" {
+				t.Fatalf("failed to read the policy:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFromUnstructuredPolicy(t *testing.T) {
+	policyTests := []struct {
+		name           string
+		policy         map[string]interface{}
+		wantPolicy     PolicyKind
+		wantDescending bool
+	}{
+		{"semver", map[string]interface{}{"semver": map[string]interface{}{"range": ">=1.0.0"}}, SemVerPolicy, false},
+		{"alphabetical", map[string]interface{}{"alphabetical": map[string]interface{}{}}, AlphabeticalPolicy, false},
+		{"alphabetical ascending", map[string]interface{}{"alphabetical": map[string]interface{}{"order": "asc"}}, AlphabeticalPolicy, false},
+		{"alphabetical descending", map[string]interface{}{"alphabetical": map[string]interface{}{"order": "desc"}}, AlphabeticalPolicy, true},
+		{"numerical", map[string]interface{}{"numerical": map[string]interface{}{}}, NumericalPolicy, false},
+		{"numerical descending", map[string]interface{}{"numerical": map[string]interface{}{"order": "desc"}}, NumericalPolicy, true},
+		{"unknown policy", map[string]interface{}{"calendar": map[string]interface{}{}}, "", false},
+		{"no policy", nil, "", false},
+	}
+
+	for _, version := range SupportedVersions {
+		for _, tt := range policyTests {
+			t.Run(version+" "+tt.name, func(t *testing.T) {
+				fields := map[string]interface{}{}
+				if tt.policy != nil {
+					fields["spec"] = map[string]interface{}{"policy": tt.policy}
+				}
+
+				policy, err := FromUnstructured(makePolicy(version, fields))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if policy.Policy != tt.wantPolicy || policy.Descending != tt.wantDescending {
+					t.Fatalf("got policy %q descending %v, want %q descending %v", policy.Policy, policy.Descending, tt.wantPolicy, tt.wantDescending)
+				}
+			})
+		}
+	}
+}
+
+func TestFromUnstructuredWithInvalidStatus(t *testing.T) {
+	policy := makePolicy("v1beta1", map[string]interface{}{
+		"status": map[string]interface{}{"latestImage": 3},
+	})
+
+	_, err := FromUnstructured(policy)
+
+	want := "failed to read status.latestImage of ImagePolicy test-ns/go-demo: .status.latestImage accessor error: 3 is of the type int, expected string"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+}
+
+func TestReaderGet(t *testing.T) {
+	c := fake.NewFakeClientWithScheme(runtime.NewScheme(), makePolicy("v1beta2", map[string]interface{}{
+		"status": map[string]interface{}{"latestRef": map[string]interface{}{"name": "bigkevmcd/go-demo", "tag": "v1.1.0"}},
+	}))
+
+	policy, err := Reader{Version: "v1beta2"}.Get(context.Background(), c, types.NamespacedName{Name: "go-demo", Namespace: "test-ns"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if policy.LatestImage != "bigkevmcd/go-demo:v1.1.0" {
+		t.Fatalf("got latest image %q, want %q", policy.LatestImage, "bigkevmcd/go-demo:v1.1.0")
+	}
+}
+
+func TestServedVersion(t *testing.T) {
+	versionTests := []struct {
+		name    string
+		served  []string
+		want    string
+		wantErr string
+	}{
+		{"only v1alpha1", []string{"v1alpha1"}, "v1alpha1", ""},
+		{"v1beta1 and v1beta2", []string{"v1beta1", "v1beta2"}, "v1beta2", ""},
+		{"unsupported version", []string{"v1"}, "", "the API server serves none of the supported ImagePolicy versions [v1beta2 v1beta1 v1alpha2 v1alpha1]"},
+	}
+
+	for _, tt := range versionTests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+			for _, v := range tt.served {
+				d.Resources = append(d.Resources, &metav1.APIResourceList{
+					GroupVersion: Group + "/" + v,
+					APIResources: []metav1.APIResource{{Name: "imagepolicies"}},
+				})
+			}
+
+			version, err := ServedVersion(d)
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != tt.want {
+				t.Fatalf("got version %q, want %q", version, tt.want)
+			}
+		})
+	}
+}
+
+func makePolicy(version string, fields map[string]interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: fields}
+	u.SetGroupVersionKind(Reader{Version: version}.GroupVersionKind())
+	u.SetName("go-demo")
+	u.SetNamespace("test-ns")
+	u.SetGeneration(2)
+	return u
+}
@@ -0,0 +1,245 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ManifestMediaTypes are the manifest formats that are accepted from the
+// registry.
+var ManifestMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}
+
+// ErrNotFound is wrapped by errors for manifests and blobs that don't exist.
+var ErrNotFound = errors.New("not found")
+
+// Descriptor describes a manifest in a registry.
+type Descriptor struct {
+	MediaType string
+	Digest    string
+	Size      int64
+}
+
+// Client is a client for the read-only parts of the OCI distribution API.
+//
+// Requests are anonymous, if the registry responds with a challenge, the
+// request is retried with a bearer token, or basic authentication, using the
+// credentials for the registry if there are any.
+type Client struct {
+	httpClient  *http.Client
+	credentials Credentials
+}
+
+// NewClient creates and returns a new Client.
+func NewClient(httpClient *http.Client) *Client {
+	return &Client{httpClient: httpClient}
+}
+
+// WithCredentials returns a copy of the client that authenticates with the
+// credentials.
+func (c *Client) WithCredentials(creds Credentials) *Client {
+	return &Client{httpClient: c.httpClient, credentials: creds}
+}
+
+// Head fetches the descriptor of the referenced manifest, without fetching the
+// manifest.
+func (c *Client) Head(ctx context.Context, ref Reference) (Descriptor, error) {
+	resp, err := c.do(ctx, http.MethodHead, ref, "/manifests/"+ref.Identifier(), ManifestMediaTypes)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	defer resp.Body.Close()
+	return descriptorFrom(resp), nil
+}
+
+// Manifest fetches the referenced manifest, and its descriptor.
+func (c *Client) Manifest(ctx context.Context, ref Reference) ([]byte, Descriptor, error) {
+	resp, err := c.do(ctx, http.MethodGet, ref, "/manifests/"+ref.Identifier(), ManifestMediaTypes)
+	if err != nil {
+		return nil, Descriptor{}, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, Descriptor{}, fmt.Errorf("failed to read the manifest for %s: %w", ref, err)
+	}
+	return body, descriptorFrom(resp), nil
+}
+
+// Blob fetches a blob from the referenced repository.
+func (c *Client) Blob(ctx context.Context, ref Reference, digest string) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, ref, "/blobs/"+digest, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s from %s: %w", digest, ref.Repository, err)
+	}
+	return body, nil
+}
+
+func descriptorFrom(resp *http.Response) Descriptor {
+	return Descriptor{
+		MediaType: resp.Header.Get("Content-Type"),
+		Digest:    resp.Header.Get("Docker-Content-Digest"),
+		Size:      resp.ContentLength,
+	}
+}
+
+// do makes a request to the registry, handling bearer token challenges.
+//
+// The response is only returned if it was successful, and the body must be
+// closed.
+func (c *Client) do(ctx context.Context, method string, ref Reference, path string, accept []string) (*http.Response, error) {
+	u := "https://" + ref.Registry + "/v2/" + ref.Repository + path
+	resp, err := c.send(ctx, method, u, accept, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		authorization, err := c.authorization(ctx, challenge, ref)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = c.send(ctx, method, u, accept, authorization)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s%s in %s: %w", ref.Repository, path, ref.Registry, ErrNotFound)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("registry request %s %s failed with status %d: %s", method, u, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func (c *Client) send(ctx context.Context, method, u string, accept []string, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the request: %w", err)
+	}
+	req = req.WithContext(ctx)
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the registry: %w", err)
+	}
+	return resp, nil
+}
+
+// authorization returns the Authorization header that answers the
+// challenge.
+func (c *Client) authorization(ctx context.Context, challenge string, ref Reference) (string, error) {
+	cred, hasCred := c.credentials[ref.Registry]
+	if hasCred && strings.HasPrefix(strings.ToLower(challenge), "basic") {
+		return basicAuth(cred), nil
+	}
+	params, ok := parseBearerChallenge(challenge)
+	if !ok || params["realm"] == "" {
+		return "", fmt.Errorf("registry %s requires authentication, unsupported challenge %q", ref.Registry, challenge)
+	}
+	authorization := ""
+	if hasCred {
+		authorization = basicAuth(cred)
+	}
+	token, err := c.token(ctx, params, authorization, ref)
+	if err != nil {
+		return "", err
+	}
+	return "Bearer " + token, nil
+}
+
+func basicAuth(cred Credential) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(cred.Username+":"+cred.Password))
+}
+
+// token requests a pull token for the repository from the realm in a bearer
+// token challenge.
+func (c *Client) token(ctx context.Context, params map[string]string, authorization string, ref Reference) (string, error) {
+	q := url.Values{}
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	q.Set("scope", "repository:"+ref.Repository+":pull")
+	resp, err := c.send(ctx, http.MethodGet, params["realm"]+"?"+q.Encode(), nil, authorization)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get a token for %s from %s: status %d", ref.Repository, params["realm"], resp.StatusCode)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode the token response: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// parseBearerChallenge parses the parameters of a WWW-Authenticate header
+// e.g. `Bearer realm="https://auth.docker.io/token",service="registry.docker.io"`.
+func parseBearerChallenge(header string) (map[string]string, bool) {
+	const prefix = "bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return nil, false
+	}
+	params := map[string]string{}
+	rest := strings.TrimSpace(header[len(prefix):])
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return nil, false
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.Index(rest, ",")
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		params[key] = value
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	return params, true
+}
@@ -0,0 +1,107 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/registry/registrytest"
+)
+
+const testManifestType = "application/vnd.oci.image.manifest.v1+json"
+
+func TestHead(t *testing.T) {
+	reg := registrytest.NewServer(t)
+	body := []byte(`{"schemaVersion":2}`)
+	digest := reg.AddManifest("bigkevmcd/go-demo", "v1.2.3", testManifestType, body)
+	client := NewClient(reg.Client())
+
+	desc, err := client.Head(context.Background(), Reference{Registry: reg.Host(), Repository: "bigkevmcd/go-demo", Tag: "v1.2.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Descriptor{MediaType: testManifestType, Digest: digest, Size: int64(len(body))}
+	if diff := cmp.Diff(want, desc); diff != "" {
+		t.Fatalf("failed to get the descriptor:\n%s", diff)
+	}
+	wantRequests := []string{
+		"HEAD /v2/bigkevmcd/go-demo/manifests/v1.2.3",
+		"GET /token",
+		"HEAD /v2/bigkevmcd/go-demo/manifests/v1.2.3",
+	}
+	if diff := cmp.Diff(wantRequests, reg.Requests()); diff != "" {
+		t.Fatalf("failed to authenticate:\n%s", diff)
+	}
+}
+
+func TestHeadNotFound(t *testing.T) {
+	reg := registrytest.NewServer(t)
+	client := NewClient(reg.Client())
+
+	_, err := client.Head(context.Background(), Reference{Registry: reg.Host(), Repository: "bigkevmcd/go-demo", Tag: "v1.2.3"})
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got error %v, want ErrNotFound", err)
+	}
+}
+
+func TestManifestAndBlob(t *testing.T) {
+	reg := registrytest.NewServer(t)
+	body := []byte(`{"schemaVersion":2}`)
+	digest := reg.AddManifest("bigkevmcd/go-demo", "", testManifestType, body)
+	blobDigest := reg.AddBlob([]byte("test blob"))
+	client := NewClient(reg.Client())
+	ref := Reference{Registry: reg.Host(), Repository: "bigkevmcd/go-demo", Digest: digest}
+
+	manifest, desc, err := client.Manifest(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(manifest) != string(body) || desc.Digest != digest {
+		t.Fatalf("got manifest %s with digest %s, want %s with %s", manifest, desc.Digest, body, digest)
+	}
+
+	blob, err := client.Blob(context.Background(), ref, blobDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(blob) != "test blob" {
+		t.Fatalf("got blob %q, want %q", blob, "test blob")
+	}
+}
+
+func TestParseBearerChallenge(t *testing.T) {
+	params, ok := parseBearerChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`)
+	if !ok {
+		t.Fatal("failed to parse the challenge")
+	}
+
+	want := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull",
+	}
+	if diff := cmp.Diff(want, params); diff != "" {
+		t.Fatalf("failed to parse the challenge:\n%s", diff)
+	}
+}
+
+func TestHeadWithCredentials(t *testing.T) {
+	reg := registrytest.NewServer(t)
+	reg.RequireCredentials("testuser", "testpass")
+	reg.AddManifest("bigkevmcd/go-demo", "v1.2.3", testManifestType, []byte(`{"schemaVersion":2}`))
+	ref := Reference{Registry: reg.Host(), Repository: "bigkevmcd/go-demo", Tag: "v1.2.3"}
+	client := NewClient(reg.Client())
+
+	if _, err := client.Head(context.Background(), ref); err == nil {
+		t.Fatal("expected the anonymous request to fail")
+	}
+
+	client = client.WithCredentials(Credentials{reg.Host(): {Username: "testuser", Password: "testpass"}})
+	if _, err := client.Head(context.Background(), ref); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Credential is a username and password for a registry.
+type Credential struct {
+	Username string
+	Password string
+}
+
+// Credentials are the credentials for registries, keyed by the host of the
+// registry API.
+type Credentials map[string]Credential
+
+// ParseDockerConfig parses the credentials in a Docker config file, the
+// format of "kubernetes.io/dockerconfigjson" Secrets.
+func ParseDockerConfig(data []byte) (Credentials, error) {
+	var config struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse the docker config: %w", err)
+	}
+	creds := Credentials{}
+	for server, auth := range config.Auths {
+		cred := Credential{Username: auth.Username, Password: auth.Password}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode the auth for %s: %w", server, err)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("the auth for %s is not a username and password", server)
+			}
+			cred = Credential{Username: parts[0], Password: parts[1]}
+		}
+		creds[registryHost(server)] = cred
+	}
+	return creds, nil
+}
+
+// Merge adds the credentials from other, replacing any for the same
+// registry.
+func (c Credentials) Merge(other Credentials) {
+	for k, v := range other {
+		c[k] = v
+	}
+}
+
+// registryHost returns the host of the registry API for a server in a Docker
+// config e.g. "https://index.docker.io/v1/" is "registry-1.docker.io".
+func registryHost(server string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	switch host {
+	case dockerHub, "index.docker.io":
+		return dockerHubRegistry
+	}
+	return host
+}
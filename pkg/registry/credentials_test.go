@@ -0,0 +1,45 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDockerConfig(t *testing.T) {
+	config := []byte(`{"auths":{
+		"https://index.docker.io/v1/":{"auth":"dGVzdHVzZXI6dGVzdDpwYXNz"},
+		"quay.io":{"username":"quayuser","password":"quaypass"}
+	}}`)
+
+	creds, err := ParseDockerConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Credentials{
+		"registry-1.docker.io": {Username: "testuser", Password: "test:pass"},
+		"quay.io":              {Username: "quayuser", Password: "quaypass"},
+	}
+	if diff := cmp.Diff(want, creds); diff != "" {
+		t.Fatalf("failed to parse the config:\n%s", diff)
+	}
+}
+
+func TestParseDockerConfigErrors(t *testing.T) {
+	errorTests := []struct {
+		config  string
+		wantErr string
+	}{
+		{`{"auths":`, "failed to parse the docker config: unexpected end of JSON input"},
+		{`{"auths":{"quay.io":{"auth":"!"}}}`, "failed to decode the auth for quay.io: illegal base64 data at input byte 0"},
+		{`{"auths":{"quay.io":{"auth":"dGVzdA=="}}}`, "the auth for quay.io is not a username and password"},
+	}
+
+	for _, tt := range errorTests {
+		_, err := ParseDockerConfig([]byte(tt.config))
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("ParseDockerConfig(%s) got error %v, want %q", tt.config, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	dockerHub         = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+)
+
+// Reference identifies an image manifest in a registry.
+type Reference struct {
+	// Registry is the host of the registry API e.g. "registry-1.docker.io".
+	Registry string
+	// Repository is the path of the repository in the registry e.g.
+	// "bigkevmcd/go-demo".
+	Repository string
+	// Tag is the tag of the image, it is empty if the image has a digest.
+	Tag string
+	// Digest is the digest of the image e.g. "sha256:...".
+	Digest string
+}
+
+// ParseReference parses an image reference e.g.
+// "docker.io/bigkevmcd/go-demo:af93dae".
+//
+// Images without a registry are in Docker Hub, and images without a tag or
+// digest have the tag "latest".
+func ParseReference(image string) (Reference, error) {
+	var ref Reference
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+	if name == "" {
+		return Reference{}, fmt.Errorf("failed to parse image %q: no repository", image)
+	}
+
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.Registry, ref.Repository = parts[0], parts[1]
+	} else {
+		ref.Registry, ref.Repository = dockerHub, name
+	}
+	if ref.Registry == dockerHub {
+		ref.Registry = dockerHubRegistry
+		if !strings.Contains(ref.Repository, "/") {
+			ref.Repository = "library/" + ref.Repository
+		}
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+// Identifier returns the digest, or the tag if there is no digest.
+func (r Reference) Identifier() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// WithRegistry returns a copy of the reference in a different registry,
+// e.g. a mirror.
+func (r Reference) WithRegistry(registry string) Reference {
+	r.Registry = registry
+	return r
+}
+
+// WithTag returns a copy of the reference with the tag, and no digest.
+func (r Reference) WithTag(tag string) Reference {
+	r.Tag = tag
+	r.Digest = ""
+	return r
+}
+
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
@@ -0,0 +1,32 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseReference(t *testing.T) {
+	parseTests := []struct {
+		image string
+		want  Reference
+	}{
+		{"nginx", Reference{Registry: "registry-1.docker.io", Repository: "library/nginx", Tag: "latest"}},
+		{"docker.io/bigkevmcd/go-demo:af93dae", Reference{Registry: "registry-1.docker.io", Repository: "bigkevmcd/go-demo", Tag: "af93dae"}},
+		{"quay.io/bigkevmcd/go-demo:v1.2.3", Reference{Registry: "quay.io", Repository: "bigkevmcd/go-demo", Tag: "v1.2.3"}},
+		{"localhost:5000/go-demo", Reference{Registry: "localhost:5000", Repository: "go-demo", Tag: "latest"}},
+		{"quay.io/bigkevmcd/go-demo@sha256:12ab", Reference{Registry: "quay.io", Repository: "bigkevmcd/go-demo", Digest: "sha256:12ab"}},
+		{"quay.io/bigkevmcd/go-demo:v1@sha256:12ab", Reference{Registry: "quay.io", Repository: "bigkevmcd/go-demo", Tag: "v1", Digest: "sha256:12ab"}},
+	}
+
+	for _, tt := range parseTests {
+		got, err := ParseReference(tt.image)
+		if err != nil {
+			t.Errorf("ParseReference(%q) failed: %s", tt.image, err)
+			continue
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("ParseReference(%q) failed:\n%s", tt.image, diff)
+		}
+	}
+}
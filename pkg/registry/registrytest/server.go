@@ -0,0 +1,142 @@
+// Package registrytest provides an in-memory OCI registry for tests.
+package registrytest
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testToken = "registry-token"
+
+// Server is a minimal registry that serves manifests and blobs, and requires
+// a bearer token obtained from its token endpoint.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	manifests map[string]manifest
+	blobs     map[string][]byte
+	requests  []string
+	username  string
+	password  string
+}
+
+type manifest struct {
+	mediaType string
+	body      []byte
+}
+
+// NewServer starts a new TLS registry, which is closed when the test
+// completes.
+func NewServer(t *testing.T) *Server {
+	s := &Server{manifests: map[string]manifest{}, blobs: map[string][]byte{}}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Host is the host to use in image references for the registry.
+func (s *Server) Host() string {
+	return strings.TrimPrefix(s.URL, "https://")
+}
+
+// AddManifest adds a manifest to the repository with the tag, and returns its
+// digest, the manifest can be fetched by tag or digest.
+func (s *Server) AddManifest(repository, tag, mediaType string, body []byte) string {
+	digest := Digest(body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := manifest{mediaType: mediaType, body: body}
+	s.manifests[repository+"@"+digest] = m
+	if tag != "" {
+		s.manifests[repository+":"+tag] = m
+	}
+	return digest
+}
+
+// AddBlob adds a blob to the registry, and returns its digest.
+func (s *Server) AddBlob(body []byte) string {
+	digest := Digest(body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[digest] = body
+	return digest
+}
+
+// RequireCredentials makes the token endpoint require basic authentication
+// with the username and password.
+func (s *Server) RequireCredentials(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.username, s.password = username, password
+}
+
+// Requests returns the method and path of the requests the registry received.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// Digest returns the sha256 digest of the data.
+func Digest(b []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(b))
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	if r.URL.Path == "/token" {
+		if username, password, _ := r.BasicAuth(); s.username != "" && (username != s.username || password != s.password) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": testToken})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+testToken {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registrytest"`, s.URL))
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	if i := strings.LastIndex(path, "/manifests/"); i >= 0 {
+		repository, reference := path[:i], path[i+len("/manifests/"):]
+		separator := ":"
+		if strings.HasPrefix(reference, "sha256:") {
+			separator = "@"
+		}
+		m, ok := s.manifests[repository+separator+reference]
+		if !ok {
+			http.Error(w, "manifest unknown", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", m.mediaType)
+		w.Header().Set("Docker-Content-Digest", Digest(m.body))
+		w.Header().Set("Content-Length", fmt.Sprint(len(m.body)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(m.body)
+		}
+		return
+	}
+	if i := strings.LastIndex(path, "/blobs/"); i >= 0 {
+		b, ok := s.blobs[path[i+len("/blobs/"):]]
+		if !ok {
+			http.Error(w, "blob unknown", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(b)
+		return
+	}
+	http.Error(w, "not found", http.StatusNotFound)
+}
@@ -0,0 +1,113 @@
+package remote
+
+import (
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewClientFunc creates a client from a REST configuration.
+type NewClientFunc func(*rest.Config) (client.Client, error)
+
+// ClientCache keeps clients for remote clusters, keyed by the Secret and the
+// key in the Secret that their kubeconfig was loaded from.
+//
+// When the Secret changes, the client is recreated from the new kubeconfig.
+type ClientCache struct {
+	newClient NewClientFunc
+
+	mu      sync.Mutex
+	clients map[cacheKey]cachedClient
+}
+
+// cacheKey identifies a kubeconfig, a Secret can hold several kubeconfigs in
+// different keys.
+type cacheKey struct {
+	secret types.NamespacedName
+	key    string
+}
+
+type cachedClient struct {
+	resourceVersion string
+	client          client.Client
+}
+
+// ConnectionError is returned when the kubeconfig was loaded, but a client
+// could not be created for the cluster.
+type ConnectionError struct {
+	Secret types.NamespacedName
+	Err    error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("failed to create a client for the kubeconfig in %s: %s", e.Secret, e.Err)
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// NewClientCache creates and returns a new ClientCache that creates clients
+// with the provided function.
+func NewClientCache(f NewClientFunc) *ClientCache {
+	return &ClientCache{
+		newClient: f,
+		clients:   map[cacheKey]cachedClient{},
+	}
+}
+
+// ClientFor returns a client for the cluster in the kubeconfig stored in the
+// secret at the provided key.
+//
+// Clients are reused until the Secret's ResourceVersion changes.
+func (c *ClientCache) ClientFor(secret *corev1.Secret, key string) (client.Client, error) {
+	name := types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}
+	ck := cacheKey{secret: name, key: key}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.clients[ck]; ok && cached.resourceVersion == secret.ResourceVersion {
+		return cached.client, nil
+	}
+	delete(c.clients, ck)
+
+	cfg, err := RESTConfigFromSecret(secret, key)
+	if err != nil {
+		return nil, err
+	}
+	cl, err := c.newClient(cfg)
+	if err != nil {
+		return nil, &ConnectionError{Secret: name, Err: err}
+	}
+	c.clients[ck] = cachedClient{resourceVersion: secret.ResourceVersion, client: cl}
+	return cl, nil
+}
+
+// Forget drops any clients that were created for the named Secret.
+func (c *ClientCache) Forget(name types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ck := range c.clients {
+		if ck.secret == name {
+			delete(c.clients, ck)
+		}
+	}
+}
+
+// RESTConfigFromSecret parses the kubeconfig in the secret at the provided
+// key.
+func RESTConfigFromSecret(secret *corev1.Secret, key string) (*rest.Config, error) {
+	data, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no key %q", secret.Namespace, secret.Name, key)
+	}
+	cfg, err := clientcmd.RESTConfigFromKubeConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the kubeconfig in secret %s/%s: %w", secret.Namespace, secret.Name, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,188 @@
+package remote
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: remote
+  cluster:
+    server: https://remote.example.com:6443
+contexts:
+- name: remote
+  context:
+    cluster: remote
+    user: remote
+current-context: remote
+users:
+- name: remote
+  user:
+    token: testing
+`
+
+func TestClientForReusesClients(t *testing.T) {
+	created := 0
+	cache := NewClientCache(countingClient(&created))
+	secret := makeSecret("1", testKubeConfig)
+
+	first, err := cache.ClientFor(secret, "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := cache.ClientFor(secret, "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Fatal("expected the cached client to be returned")
+	}
+	if created != 1 {
+		t.Fatalf("got %d clients created, want 1", created)
+	}
+}
+
+func TestClientForRecreatesClientWhenSecretChanges(t *testing.T) {
+	var hosts []string
+	cache := NewClientCache(func(cfg *rest.Config) (client.Client, error) {
+		hosts = append(hosts, cfg.Host)
+		return fake.NewFakeClient(), nil
+	})
+
+	_, err := cache.ClientFor(makeSecret("1", testKubeConfig), "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cache.ClientFor(makeSecret("2", testKubeConfig), "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := len(hosts); l != 2 {
+		t.Fatalf("got %d clients created, want 2", l)
+	}
+	if hosts[1] != "https://remote.example.com:6443" {
+		t.Fatalf("got host %q, want %q", hosts[1], "https://remote.example.com:6443")
+	}
+}
+
+func TestClientForCachesEachKey(t *testing.T) {
+	created := 0
+	cache := NewClientCache(countingClient(&created))
+	secret := makeSecret("1", testKubeConfig)
+	secret.Data["other"] = secret.Data["value"]
+
+	for _, key := range []string{"value", "other", "value", "other"} {
+		if _, err := cache.ClientFor(secret, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if created != 2 {
+		t.Fatalf("got %d clients created, want 2", created)
+	}
+}
+
+func TestClientForForget(t *testing.T) {
+	created := 0
+	cache := NewClientCache(countingClient(&created))
+	secret := makeSecret("1", testKubeConfig)
+
+	if _, err := cache.ClientFor(secret, "value"); err != nil {
+		t.Fatal(err)
+	}
+	cache.Forget(types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace})
+	if _, err := cache.ClientFor(secret, "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	if created != 2 {
+		t.Fatalf("got %d clients created, want 2", created)
+	}
+}
+
+func TestClientForErrors(t *testing.T) {
+	errTests := []struct {
+		desc      string
+		secret    *corev1.Secret
+		key       string
+		newClient NewClientFunc
+		wantErr   string
+	}{
+		{
+			desc:      "missing key",
+			secret:    makeSecret("1", testKubeConfig),
+			key:       "unknown",
+			newClient: countingClient(new(int)),
+			wantErr:   `secret test-ns/test-kubeconfig has no key "unknown"`,
+		},
+		{
+			desc:      "invalid kubeconfig",
+			secret:    makeSecret("1", "this is not a kubeconfig"),
+			key:       "value",
+			newClient: countingClient(new(int)),
+			wantErr:   "failed to parse the kubeconfig in secret test-ns/test-kubeconfig",
+		},
+		{
+			desc:   "failing to connect",
+			secret: makeSecret("1", testKubeConfig),
+			key:    "value",
+			newClient: func(*rest.Config) (client.Client, error) {
+				return nil, errors.New("connection refused")
+			},
+			wantErr: "failed to create a client for the kubeconfig in test-ns/test-kubeconfig: connection refused",
+		},
+	}
+
+	for _, tt := range errTests {
+		cache := NewClientCache(tt.newClient)
+		_, err := cache.ClientFor(tt.secret, tt.key)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: got error %v, want %q", tt.desc, err, tt.wantErr)
+		}
+	}
+}
+
+func TestClientForConnectionError(t *testing.T) {
+	cache := NewClientCache(func(*rest.Config) (client.Client, error) {
+		return nil, errors.New("connection refused")
+	})
+
+	_, err := cache.ClientFor(makeSecret("1", testKubeConfig), "value")
+
+	var connErr *ConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("got error %#v, want a ConnectionError", err)
+	}
+}
+
+func countingClient(n *int) NewClientFunc {
+	return func(*rest.Config) (client.Client, error) {
+		*n++
+		return fake.NewFakeClient(), nil
+	}
+}
+
+func makeSecret(rv, kubeConfig string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-kubeconfig",
+			Namespace:       "test-ns",
+			ResourceVersion: rv,
+		},
+		Data: map[string][]byte{
+			"value": []byte(kubeConfig),
+		},
+	}
+}
@@ -0,0 +1,144 @@
+// Package selection selects the image that an updater applies, it is shared by
+// the controller and the command-line tool so that they choose the same image.
+package selection
+
+import (
+	"fmt"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/imagepolicy"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/tags"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/update"
+)
+
+// Selection is the image that an updater applies to a source, and the checks
+// that stop it from being applied.
+//
+// The registry checks, that the image exists and is signed, are not part of
+// the selection.
+type Selection struct {
+	// Image is the pinned image or the ImagePolicy's latest image, it is
+	// empty if the ImagePolicy hasn't selected one.
+	Image string
+	// Pinned is true if the Image is the updater's pinned image.
+	Pinned bool
+	// Current is the image in the source with the same name as the Image.
+	Current string
+
+	// Rejection is why the tag filter rejected the Image.
+	Rejection error
+	// Downgrade is why the Image is blocked as older than the Current image.
+	Downgrade error
+}
+
+// Select selects the image that the updater applies to the source.
+//
+// Pinned images skip the tag filter and downgrade protection. The error is
+// returned if the tag filter can't be parsed.
+func Select(updater *appsv1alpha1.ImagePolicyArgoCDUpdate, imagePolicy *imagepolicy.ImagePolicy, src *application.ApplicationSource) (*Selection, error) {
+	sel := &Selection{}
+	sel.Image, sel.Pinned = DesiredImage(updater, imagePolicy)
+	if sel.Image == "" {
+		return sel, nil
+	}
+	sel.Current = update.CurrentImage(src, updater.Spec.Strategy, sel.Image)
+	if sel.Pinned {
+		return sel, nil
+	}
+	rejection, err := FilterImage(updater, sel.Image)
+	if err != nil {
+		return nil, err
+	}
+	if rejection != nil {
+		sel.Rejection = rejection
+		return sel, nil
+	}
+	sel.Downgrade = CheckDowngrade(updater, imagePolicy, sel.Current, sel.Image)
+	return sel, nil
+}
+
+// DesiredImage returns the image that the updater applies, the pinned image if
+// there is one, or the ImagePolicy's latest image.
+//
+// The ImagePolicy can be nil if the updater is pinned.
+func DesiredImage(updater *appsv1alpha1.ImagePolicyArgoCDUpdate, imagePolicy *imagepolicy.ImagePolicy) (image string, pinned bool) {
+	if updater.Spec.PinnedImage != "" {
+		return updater.Spec.PinnedImage, true
+	}
+	if imagePolicy == nil {
+		return "", false
+	}
+	return imagePolicy.LatestImage, false
+}
+
+// FilterImage checks the image's tag against the updater's tag filter.
+//
+// The rejection describes why the image was rejected, or is nil if it was
+// accepted, the error is returned if the filter can't be parsed.
+func FilterImage(updater *appsv1alpha1.ImagePolicyArgoCDUpdate, image string) (rejection error, err error) {
+	if updater.Spec.TagFilter == nil {
+		return nil, nil
+	}
+	filter, err := tags.NewFilter(*updater.Spec.TagFilter)
+	if err != nil {
+		return nil, err
+	}
+	return filter.Check(update.ParseImage(image).Tag), nil
+}
+
+// CheckDowngrade returns an error if the updater has downgrade protection, and
+// the latest image is older than the current image.
+//
+// Images that can't be compared because they have no tag are not blocked, but
+// tags that can't be parsed with the ordering are.
+func CheckDowngrade(updater *appsv1alpha1.ImagePolicyArgoCDUpdate, imagePolicy *imagepolicy.ImagePolicy, current, latest string) error {
+	protection := updater.Spec.DowngradeProtection
+	if protection == nil || current == "" || current == latest || latest == protection.AllowedImage {
+		return nil
+	}
+	currentTag, latestTag := update.ParseImage(current).Tag, update.ParseImage(latest).Tag
+	if currentTag == "" || latestTag == "" {
+		return nil
+	}
+	ordering, descending := DowngradeOrdering(protection, imagePolicy)
+	var downgrade bool
+	var err error
+	if descending {
+		// The policy selects the lowest tag, so a higher tag is older.
+		downgrade, err = tags.IsDowngrade(ordering, latestTag, currentTag)
+	} else {
+		downgrade, err = tags.IsDowngrade(ordering, currentTag, latestTag)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to compare image %s with the current image %s: %w", latest, current, err)
+	}
+	if downgrade {
+		order := string(ordering)
+		if descending {
+			order = "descending " + order
+		}
+		return fmt.Errorf("image %s is older than the current image %s by %s ordering", latest, current, order)
+	}
+	return nil
+}
+
+// DowngradeOrdering returns the ordering that the updater uses to compare
+// tags, matching the ImagePolicy's policy and order if no ordering is
+// configured.
+//
+// If descending is true, newer images have lower tags in the ordering.
+func DowngradeOrdering(protection *appsv1alpha1.DowngradeProtection, imagePolicy *imagepolicy.ImagePolicy) (ordering appsv1alpha1.TagOrdering, descending bool) {
+	if protection.Ordering != "" {
+		return protection.Ordering, false
+	}
+	switch imagePolicy.Policy {
+	case imagepolicy.SemVerPolicy:
+		return appsv1alpha1.SemVerOrdering, false
+	case imagepolicy.NumericalPolicy:
+		return appsv1alpha1.NumericalOrdering, imagePolicy.Descending
+	case imagepolicy.AlphabeticalPolicy:
+		return appsv1alpha1.AlphabeticalOrdering, imagePolicy.Descending
+	}
+	return appsv1alpha1.AlphabeticalOrdering, false
+}
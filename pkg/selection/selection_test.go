@@ -0,0 +1,191 @@
+package selection
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/imagepolicy"
+)
+
+func TestSelect(t *testing.T) {
+	semver := map[string]interface{}{"semver": map[string]interface{}{}}
+	selectTests := []struct {
+		name          string
+		spec          appsv1alpha1.ImagePolicyArgoCDUpdateSpec
+		latest        string
+		current       string
+		want          Selection
+		wantRejection string
+		wantDowngrade string
+	}{
+		{"no latest image", appsv1alpha1.ImagePolicyArgoCDUpdateSpec{}, "", "go-demo:v1.0.0",
+			Selection{}, "", ""},
+		{"latest image", appsv1alpha1.ImagePolicyArgoCDUpdateSpec{}, "go-demo:v1.1.0", "go-demo:v1.0.0",
+			Selection{Image: "go-demo:v1.1.0", Current: "go-demo:v1.0.0"}, "", ""},
+		{"pinned image", appsv1alpha1.ImagePolicyArgoCDUpdateSpec{PinnedImage: "go-demo:v0.9.0"}, "go-demo:v1.1.0", "go-demo:v1.0.0",
+			Selection{Image: "go-demo:v0.9.0", Pinned: true, Current: "go-demo:v1.0.0"}, "", ""},
+		{"pinned image skips the checks", appsv1alpha1.ImagePolicyArgoCDUpdateSpec{
+			PinnedImage:         "go-demo:v0.9.0-rc.1",
+			TagFilter:           &appsv1alpha1.TagFilter{Exclude: "-rc"},
+			DowngradeProtection: &appsv1alpha1.DowngradeProtection{},
+		}, "go-demo:v1.1.0", "go-demo:v1.0.0",
+			Selection{Image: "go-demo:v0.9.0-rc.1", Pinned: true, Current: "go-demo:v1.0.0"}, "", ""},
+		{"filtered image", appsv1alpha1.ImagePolicyArgoCDUpdateSpec{TagFilter: &appsv1alpha1.TagFilter{Exclude: "-rc"}}, "go-demo:v1.1.0-rc.1", "go-demo:v1.0.0",
+			Selection{Image: "go-demo:v1.1.0-rc.1", Current: "go-demo:v1.0.0"}, `tag "v1.1.0-rc.1" matches the excluded "-rc"`, ""},
+		{"downgraded image", appsv1alpha1.ImagePolicyArgoCDUpdateSpec{DowngradeProtection: &appsv1alpha1.DowngradeProtection{}}, "go-demo:v0.9.0", "go-demo:v1.0.0",
+			Selection{Image: "go-demo:v0.9.0", Current: "go-demo:v1.0.0"}, "", "image go-demo:v0.9.0 is older than the current image go-demo:v1.0.0 by SemVer ordering"},
+	}
+
+	for _, tt := range selectTests {
+		t.Run(tt.name, func(t *testing.T) {
+			updater := &appsv1alpha1.ImagePolicyArgoCDUpdate{Spec: tt.spec}
+			policy := readImagePolicy(t, "v1beta2", semver)
+			policy.LatestImage = tt.latest
+			src := &application.ApplicationSource{
+				Kustomize: &application.ApplicationSourceKustomize{Images: application.KustomizeImages{application.KustomizeImage(tt.current)}},
+			}
+
+			sel, err := Select(updater, policy, src)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if msg := errorString(sel.Rejection); msg != tt.wantRejection {
+				t.Errorf("got rejection %q, want %q", msg, tt.wantRejection)
+			}
+			if msg := errorString(sel.Downgrade); msg != tt.wantDowngrade {
+				t.Errorf("got downgrade %q, want %q", msg, tt.wantDowngrade)
+			}
+			sel.Rejection, sel.Downgrade = nil, nil
+			if diff := cmp.Diff(&tt.want, sel); diff != "" {
+				t.Fatalf("selection failed diff -want +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSelectWithInvalidFilter(t *testing.T) {
+	updater := &appsv1alpha1.ImagePolicyArgoCDUpdate{
+		Spec: appsv1alpha1.ImagePolicyArgoCDUpdateSpec{TagFilter: &appsv1alpha1.TagFilter{Include: "("}},
+	}
+	policy := readImagePolicy(t, "v1beta2", map[string]interface{}{})
+	policy.LatestImage = "go-demo:v1.1.0"
+
+	_, err := Select(updater, policy, &application.ApplicationSource{})
+
+	if err == nil {
+		t.Fatal("expected an error for an invalid tag filter")
+	}
+}
+
+func TestDesiredImageWithoutAPolicy(t *testing.T) {
+	image, pinned := DesiredImage(&appsv1alpha1.ImagePolicyArgoCDUpdate{}, nil)
+
+	if image != "" || pinned {
+		t.Fatalf("got image %q pinned %v, want no image", image, pinned)
+	}
+}
+
+func TestDowngradeOrdering(t *testing.T) {
+	orderingTests := []struct {
+		name           string
+		policy         map[string]interface{}
+		ordering       appsv1alpha1.TagOrdering
+		wantOrdering   appsv1alpha1.TagOrdering
+		wantDescending bool
+	}{
+		{"semver", map[string]interface{}{"semver": map[string]interface{}{"range": ">=1.0.0"}}, "", appsv1alpha1.SemVerOrdering, false},
+		{"alphabetical", map[string]interface{}{"alphabetical": map[string]interface{}{}}, "", appsv1alpha1.AlphabeticalOrdering, false},
+		{"alphabetical descending", map[string]interface{}{"alphabetical": map[string]interface{}{"order": "desc"}}, "", appsv1alpha1.AlphabeticalOrdering, true},
+		{"numerical", map[string]interface{}{"numerical": map[string]interface{}{"order": "asc"}}, "", appsv1alpha1.NumericalOrdering, false},
+		{"numerical descending", map[string]interface{}{"numerical": map[string]interface{}{"order": "desc"}}, "", appsv1alpha1.NumericalOrdering, true},
+		{"no policy", map[string]interface{}{}, "", appsv1alpha1.AlphabeticalOrdering, false},
+		{"configured ordering", map[string]interface{}{"numerical": map[string]interface{}{"order": "desc"}}, appsv1alpha1.SemVerOrdering, appsv1alpha1.SemVerOrdering, false},
+	}
+
+	for _, version := range imagepolicy.SupportedVersions {
+		for _, tt := range orderingTests {
+			t.Run(version+" "+tt.name, func(t *testing.T) {
+				policy := readImagePolicy(t, version, tt.policy)
+
+				ordering, descending := DowngradeOrdering(&appsv1alpha1.DowngradeProtection{Ordering: tt.ordering}, policy)
+
+				if ordering != tt.wantOrdering || descending != tt.wantDescending {
+					t.Fatalf("got ordering %q descending %v, want %q descending %v", ordering, descending, tt.wantOrdering, tt.wantDescending)
+				}
+			})
+		}
+	}
+}
+
+func TestCheckDowngrade(t *testing.T) {
+	downgradeTests := []struct {
+		name    string
+		policy  map[string]interface{}
+		current string
+		latest  string
+		wantErr string
+	}{
+		{"semver upgrade", map[string]interface{}{"semver": map[string]interface{}{}}, "go-demo:v1.9.0", "go-demo:v1.10.0", ""},
+		{"semver downgrade", map[string]interface{}{"semver": map[string]interface{}{}}, "go-demo:v1.10.0", "go-demo:v1.9.0",
+			"image go-demo:v1.9.0 is older than the current image go-demo:v1.10.0 by SemVer ordering"},
+		{"numerical upgrade", map[string]interface{}{"numerical": map[string]interface{}{}}, "go-demo:9", "go-demo:10", ""},
+		{"numerical downgrade", map[string]interface{}{"numerical": map[string]interface{}{}}, "go-demo:10", "go-demo:9",
+			"image go-demo:9 is older than the current image go-demo:10 by Numerical ordering"},
+		{"descending numerical upgrade", map[string]interface{}{"numerical": map[string]interface{}{"order": "desc"}}, "go-demo:10", "go-demo:9", ""},
+		{"descending numerical downgrade", map[string]interface{}{"numerical": map[string]interface{}{"order": "desc"}}, "go-demo:9", "go-demo:10",
+			"image go-demo:10 is older than the current image go-demo:9 by descending Numerical ordering"},
+		{"alphabetical upgrade", map[string]interface{}{"alphabetical": map[string]interface{}{}}, "go-demo:a", "go-demo:b", ""},
+		{"descending alphabetical upgrade", map[string]interface{}{"alphabetical": map[string]interface{}{"order": "desc"}}, "go-demo:b", "go-demo:a", ""},
+		{"descending alphabetical downgrade", map[string]interface{}{"alphabetical": map[string]interface{}{"order": "desc"}}, "go-demo:a", "go-demo:b",
+			"image go-demo:b is older than the current image go-demo:a by descending Alphabetical ordering"},
+	}
+
+	for _, tt := range downgradeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			updater := &appsv1alpha1.ImagePolicyArgoCDUpdate{
+				Spec: appsv1alpha1.ImagePolicyArgoCDUpdateSpec{DowngradeProtection: &appsv1alpha1.DowngradeProtection{}},
+			}
+			policy := readImagePolicy(t, "v1beta2", tt.policy)
+
+			err := CheckDowngrade(updater, policy, tt.current, tt.latest)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got error %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// readImagePolicy reads an ImagePolicy with the spec.policy in the version.
+func readImagePolicy(t *testing.T, version string, policy map[string]interface{}) *imagepolicy.ImagePolicy {
+	t.Helper()
+	u := imagepolicy.Reader{Version: version}.NewObject()
+	u.SetName("go-demo")
+	u.SetNamespace("default")
+	if err := unstructured.SetNestedMap(u.Object, policy, "spec", "policy"); err != nil {
+		t.Fatal(err)
+	}
+	p, err := imagepolicy.FromUnstructured(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
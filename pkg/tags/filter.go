@@ -0,0 +1,61 @@
+package tags
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/Masterminds/semver"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+)
+
+// Filter accepts or rejects tags according to a TagFilter.
+type Filter struct {
+	include    *regexp.Regexp
+	exclude    *regexp.Regexp
+	constraint *semver.Constraints
+	semver     string
+}
+
+// NewFilter parses the expressions in the TagFilter.
+func NewFilter(spec appsv1alpha1.TagFilter) (*Filter, error) {
+	f := &Filter{semver: spec.SemVer}
+	var err error
+	if spec.Include != "" {
+		if f.include, err = regexp.Compile(spec.Include); err != nil {
+			return nil, fmt.Errorf("failed to parse the include expression %q: %w", spec.Include, err)
+		}
+	}
+	if spec.Exclude != "" {
+		if f.exclude, err = regexp.Compile(spec.Exclude); err != nil {
+			return nil, fmt.Errorf("failed to parse the exclude expression %q: %w", spec.Exclude, err)
+		}
+	}
+	if spec.SemVer != "" {
+		if f.constraint, err = semver.NewConstraint(spec.SemVer); err != nil {
+			return nil, fmt.Errorf("failed to parse the semver constraint %q: %w", spec.SemVer, err)
+		}
+	}
+	return f, nil
+}
+
+// Check returns an error describing why the tag is rejected, or nil if it is
+// accepted.
+func (f *Filter) Check(tag string) error {
+	if f.include != nil && !f.include.MatchString(tag) {
+		return fmt.Errorf("tag %q does not match %q", tag, f.include)
+	}
+	if f.exclude != nil && f.exclude.MatchString(tag) {
+		return fmt.Errorf("tag %q matches the excluded %q", tag, f.exclude)
+	}
+	if f.constraint != nil {
+		v, err := semver.NewVersion(tag)
+		if err != nil {
+			return fmt.Errorf("tag %q is not a semantic version", tag)
+		}
+		if !f.constraint.Check(v) {
+			return fmt.Errorf("tag %q does not satisfy %q", tag, f.semver)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package tags
+
+import (
+	"testing"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+)
+
+func TestFilter(t *testing.T) {
+	filterTests := []struct {
+		desc    string
+		spec    appsv1alpha1.TagFilter
+		tag     string
+		wantErr string
+	}{
+		{"no filters", appsv1alpha1.TagFilter{}, "1.2.3", ""},
+		{"included", appsv1alpha1.TagFilter{Include: `^\d+\.\d+\.\d+$`}, "1.2.3", ""},
+		{"not included", appsv1alpha1.TagFilter{Include: `^\d+\.\d+\.\d+$`}, "1.2.3-rc.1", `tag "1.2.3-rc.1" does not match "^\\d+\\.\\d+\\.\\d+$"`},
+		{"excluded", appsv1alpha1.TagFilter{Exclude: "-rc"}, "1.2.3-rc.1", `tag "1.2.3-rc.1" matches the excluded "-rc"`},
+		{"not excluded", appsv1alpha1.TagFilter{Exclude: "-rc"}, "1.2.3", ""},
+		{"satisfies constraint", appsv1alpha1.TagFilter{SemVer: ">= 1.2.0, < 2.0.0"}, "v1.9.0", ""},
+		{"fails constraint", appsv1alpha1.TagFilter{SemVer: ">= 1.2.0, < 2.0.0"}, "2.0.1", `tag "2.0.1" does not satisfy ">= 1.2.0, < 2.0.0"`},
+		{"not a semantic version", appsv1alpha1.TagFilter{SemVer: ">= 1.2.0"}, "af93dae", `tag "af93dae" is not a semantic version`},
+		{"all filters", appsv1alpha1.TagFilter{Include: `^v`, Exclude: "-rc", SemVer: "~1.2"}, "v1.2.5", ""},
+	}
+
+	for _, tt := range filterTests {
+		f, err := NewFilter(tt.spec)
+		if err != nil {
+			t.Errorf("%s failed to create the filter: %s", tt.desc, err)
+			continue
+		}
+		err = f.Check(tt.tag)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s failed: %s", tt.desc, err)
+		}
+		if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+			t.Errorf("%s got error %v, want %q", tt.desc, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNewFilterErrors(t *testing.T) {
+	errorTests := []struct {
+		desc    string
+		spec    appsv1alpha1.TagFilter
+		wantErr string
+	}{
+		{"bad include", appsv1alpha1.TagFilter{Include: "("}, "failed to parse the include expression \"(\": error parsing regexp: missing closing ): `(`"},
+		{"bad exclude", appsv1alpha1.TagFilter{Exclude: "["}, "failed to parse the exclude expression \"[\": error parsing regexp: missing closing ]: `[`"},
+		{"bad constraint", appsv1alpha1.TagFilter{SemVer: "> one"}, `failed to parse the semver constraint "> one": improper constraint: > one`},
+	}
+
+	for _, tt := range errorTests {
+		_, err := NewFilter(tt.spec)
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("%s got error %v, want %q", tt.desc, err, tt.wantErr)
+		}
+	}
+}
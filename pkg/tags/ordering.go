@@ -0,0 +1,61 @@
+package tags
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+)
+
+// Compare compares two tags with the ordering.
+//
+// The result is -1 if a is older than b, 0 if they are the same, and 1 if a is
+// newer than b.
+//
+// It is an error if either tag can't be parsed for the ordering e.g. "latest"
+// is not a semantic version.
+func Compare(ordering appsv1alpha1.TagOrdering, a, b string) (int, error) {
+	switch ordering {
+	case appsv1alpha1.SemVerOrdering:
+		va, err := semver.NewVersion(a)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse tag %q as a semantic version: %w", a, err)
+		}
+		vb, err := semver.NewVersion(b)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse tag %q as a semantic version: %w", b, err)
+		}
+		return va.Compare(vb), nil
+	case appsv1alpha1.NumericalOrdering:
+		fa, err := strconv.ParseFloat(a, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse tag %q as a number: %w", a, err)
+		}
+		fb, err := strconv.ParseFloat(b, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse tag %q as a number: %w", b, err)
+		}
+		switch {
+		case fa < fb:
+			return -1, nil
+		case fa > fb:
+			return 1, nil
+		}
+		return 0, nil
+	case appsv1alpha1.AlphabeticalOrdering:
+		return strings.Compare(a, b), nil
+	}
+	return 0, fmt.Errorf("unknown tag ordering %q", ordering)
+}
+
+// IsDowngrade returns true if the candidate tag is older than the current tag.
+func IsDowngrade(ordering appsv1alpha1.TagOrdering, current, candidate string) (bool, error) {
+	c, err := Compare(ordering, candidate, current)
+	if err != nil {
+		return false, err
+	}
+	return c < 0, nil
+}
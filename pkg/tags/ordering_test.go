@@ -0,0 +1,75 @@
+package tags
+
+import (
+	"testing"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+)
+
+func TestCompare(t *testing.T) {
+	compareTests := []struct {
+		ordering appsv1alpha1.TagOrdering
+		a        string
+		b        string
+		want     int
+	}{
+		{appsv1alpha1.SemVerOrdering, "1.2.3", "1.2.4", -1},
+		{appsv1alpha1.SemVerOrdering, "v1.10.0", "v1.9.0", 1},
+		{appsv1alpha1.SemVerOrdering, "1.2.3", "v1.2.3", 0},
+		{appsv1alpha1.SemVerOrdering, "1.2.3-rc.1", "1.2.3", -1},
+		{appsv1alpha1.NumericalOrdering, "9", "10", -1},
+		{appsv1alpha1.NumericalOrdering, "20200819", "20200818", 1},
+		{appsv1alpha1.NumericalOrdering, "1.5", "1.50", 0},
+		{appsv1alpha1.AlphabeticalOrdering, "9", "10", 1},
+		{appsv1alpha1.AlphabeticalOrdering, "RELEASE.2020-08-18", "RELEASE.2020-08-19", -1},
+	}
+
+	for _, tt := range compareTests {
+		got, err := Compare(tt.ordering, tt.a, tt.b)
+		if err != nil {
+			t.Errorf("Compare(%s, %q, %q) failed: %s", tt.ordering, tt.a, tt.b, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Compare(%s, %q, %q) got %d, want %d", tt.ordering, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCompareErrors(t *testing.T) {
+	errorTests := []struct {
+		ordering appsv1alpha1.TagOrdering
+		a        string
+		b        string
+		wantErr  string
+	}{
+		{appsv1alpha1.SemVerOrdering, "latest", "1.2.3", `failed to parse tag "latest" as a semantic version: Invalid Semantic Version`},
+		{appsv1alpha1.NumericalOrdering, "1", "af93dae", `failed to parse tag "af93dae" as a number: strconv.ParseFloat: parsing "af93dae": invalid syntax`},
+		{"Random", "1", "2", `unknown tag ordering "Random"`},
+	}
+
+	for _, tt := range errorTests {
+		_, err := Compare(tt.ordering, tt.a, tt.b)
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("Compare(%s, %q, %q) got error %v, want %q", tt.ordering, tt.a, tt.b, err, tt.wantErr)
+		}
+	}
+}
+
+func TestIsDowngrade(t *testing.T) {
+	downgrade, err := IsDowngrade(appsv1alpha1.SemVerOrdering, "1.2.3", "1.2.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !downgrade {
+		t.Error("1.2.3 to 1.2.2 is a downgrade")
+	}
+
+	downgrade, err = IsDowngrade(appsv1alpha1.SemVerOrdering, "1.2.3", "1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if downgrade {
+		t.Error("1.2.3 to 1.2.3 is not a downgrade")
+	}
+}
@@ -0,0 +1,26 @@
+package timing
+
+import "time"
+
+// Backoff is an exponential backoff between retries.
+type Backoff struct {
+	// Interval is the delay before the first retry.
+	Interval time.Duration
+	// Max is the longest delay.
+	Max time.Duration
+}
+
+// Delay returns the delay before the next retry, after the number of
+// consecutive failures.
+//
+// The delay doubles with each failure after the first, up to the Max.
+func (b Backoff) Delay(failures int) time.Duration {
+	delay := b.Interval
+	for i := 1; i < failures && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+	return delay
+}
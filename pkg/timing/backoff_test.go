@@ -0,0 +1,36 @@
+package timing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	backoff := Backoff{Interval: 10 * time.Second, Max: time.Minute}
+
+	delayTests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 10 * time.Second},
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{100, time.Minute},
+	}
+
+	for _, tt := range delayTests {
+		if got := backoff.Delay(tt.failures); got != tt.want {
+			t.Errorf("%d failures: got %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestBackoffDelayWithIntervalAboveMax(t *testing.T) {
+	backoff := Backoff{Interval: time.Hour, Max: time.Minute}
+
+	if got := backoff.Delay(1); got != time.Minute {
+		t.Fatalf("got %s, want %s", got, time.Minute)
+	}
+}
@@ -0,0 +1,39 @@
+package timing
+
+import "time"
+
+// Windows are the timing restrictions on applying a new image.
+type Windows struct {
+	// MinInterval is the minimum time between two updates.
+	MinInterval time.Duration
+	// StabilizationWindow is how long the latest image must be unchanged
+	// before it is applied.
+	StabilizationWindow time.Duration
+}
+
+// NextUpdate returns the earliest time that a candidate image can be applied.
+//
+// lastUpdate is when the previous image was applied, and candidateSince is when
+// the candidate image was first seen, either can be zero.
+func (w Windows) NextUpdate(lastUpdate, candidateSince time.Time) time.Time {
+	var next time.Time
+	if !lastUpdate.IsZero() && w.MinInterval > 0 {
+		next = lastUpdate.Add(w.MinInterval)
+	}
+	if !candidateSince.IsZero() && w.StabilizationWindow > 0 {
+		if stable := candidateSince.Add(w.StabilizationWindow); stable.After(next) {
+			next = stable
+		}
+	}
+	return next
+}
+
+// Wait returns how long to wait from now before a candidate image can be
+// applied, or zero if it can be applied now.
+func (w Windows) Wait(now, lastUpdate, candidateSince time.Time) time.Duration {
+	next := w.NextUpdate(lastUpdate, candidateSince)
+	if !next.After(now) {
+		return 0
+	}
+	return next.Sub(now)
+}
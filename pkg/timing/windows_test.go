@@ -0,0 +1,33 @@
+package timing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWait(t *testing.T) {
+	now := time.Date(2020, time.August, 19, 12, 0, 0, 0, time.UTC)
+	windows := Windows{MinInterval: 10 * time.Minute, StabilizationWindow: 5 * time.Minute}
+
+	waitTests := []struct {
+		desc           string
+		windows        Windows
+		lastUpdate     time.Time
+		candidateSince time.Time
+		want           time.Duration
+	}{
+		{"no windows", Windows{}, now, now, 0},
+		{"never updated, new candidate", windows, time.Time{}, now, 5 * time.Minute},
+		{"never updated, stable candidate", windows, time.Time{}, now.Add(-5 * time.Minute), 0},
+		{"recently updated, stable candidate", windows, now.Add(-2 * time.Minute), now.Add(-time.Hour), 8 * time.Minute},
+		{"min interval passed, new candidate", windows, now.Add(-time.Hour), now.Add(-time.Minute), 4 * time.Minute},
+		{"both passed", windows, now.Add(-time.Hour), now.Add(-time.Hour), 0},
+		{"min interval only", Windows{MinInterval: time.Minute}, now.Add(-30 * time.Second), now, 30 * time.Second},
+	}
+
+	for _, tt := range waitTests {
+		if got := tt.windows.Wait(now, tt.lastUpdate, tt.candidateSince); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.desc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package update
+
+import (
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+// CurrentImage returns the image with the same name as the provided image that
+// the source currently has, according to the strategy.
+//
+// If the strategy writes components of the image rather than the full image,
+// the image is reconstructed from the components.
+//
+// If the source has no such image, this returns an empty string.
+func CurrentImage(src *application.ApplicationSource, strategy *appsv1alpha1.UpdateStrategy, image string) string {
+	if strategy == nil {
+		strategy = &appsv1alpha1.UpdateStrategy{Kustomize: &appsv1alpha1.KustomizeStrategy{}}
+	}
+	img := ParseImage(image)
+	if strategy.Kustomize != nil && src.Kustomize != nil {
+		if i := src.Kustomize.Images.Find(application.KustomizeImage(image)); i >= 0 {
+			return string(src.Kustomize.Images[i])
+		}
+	}
+	if strategy.Helm != nil && src.Helm != nil {
+		values := map[string]string{}
+		for _, p := range src.Helm.Parameters {
+			values[p.Name] = p.Value
+		}
+		components := []componentValue{
+			{appsv1alpha1.ImageComponentImage, strategy.Helm.ImageParameter, values},
+			{appsv1alpha1.ImageComponentName, strategy.Helm.RepositoryParameter, values},
+			{appsv1alpha1.ImageComponentTag, strategy.Helm.TagParameter, values},
+		}
+		if len(strategy.Helm.Values) > 0 {
+			fields := helmValues(src.Helm.Values, strategy.Helm.Values)
+			for _, v := range strategy.Helm.Values {
+				components = append(components, componentValue{v.Component, v.Path, fields})
+			}
+		}
+		if current := currentFromComponents(img, components); current != "" {
+			return current
+		}
+	}
+	if strategy.Jsonnet != nil && src.Directory != nil {
+		extVars := jsonnetValues(src.Directory.Jsonnet.ExtVars)
+		tlas := jsonnetValues(src.Directory.Jsonnet.TLAs)
+		var components []componentValue
+		for _, v := range strategy.Jsonnet.ExtVars {
+			components = append(components, componentValue{v.Component, v.Name, extVars})
+		}
+		for _, v := range strategy.Jsonnet.TLAs {
+			components = append(components, componentValue{v.Component, v.Name, tlas})
+		}
+		if current := currentFromComponents(img, components); current != "" {
+			return current
+		}
+	}
+	if strategy.Plugin != nil && src.Plugin != nil {
+		env := map[string]string{}
+		for _, e := range src.Plugin.Env {
+			if e != nil {
+				env[e.Name] = e.Value
+			}
+		}
+		var components []componentValue
+		for _, v := range strategy.Plugin.Env {
+			components = append(components, componentValue{v.Component, v.Name, env})
+		}
+		if current := currentFromComponents(img, components); current != "" {
+			return current
+		}
+	}
+	if strategy.TargetRevision != nil && src.TargetRevision != "" {
+		revision := map[string]string{"targetRevision": src.TargetRevision}
+		if current := currentFromComponents(img, []componentValue{
+			{revisionComponent(strategy.TargetRevision), "targetRevision", revision},
+		}); current != "" {
+			return current
+		}
+	}
+	return ""
+}
+
+// componentValue is a variable that holds a component of an image.
+type componentValue struct {
+	component appsv1alpha1.ImageComponent
+	name      string
+	values    map[string]string
+}
+
+// currentFromComponents reconstructs the current image from the variables that
+// hold its components.
+//
+// Components that are not held in a variable are taken from the new image.
+func currentFromComponents(img Image, components []componentValue) string {
+	current := img
+	found := false
+	for _, c := range components {
+		v, ok := c.values[c.name]
+		if c.name == "" || !ok {
+			continue
+		}
+		found = true
+		switch c.component {
+		case "", appsv1alpha1.ImageComponentImage:
+			return v
+		case appsv1alpha1.ImageComponentName:
+			current.Name = v
+		case appsv1alpha1.ImageComponentTag:
+			current.Tag = v
+		case appsv1alpha1.ImageComponentDigest:
+			current.Digest = v
+		}
+	}
+	if !found {
+		return ""
+	}
+	return current.String()
+}
+
+func jsonnetValues(vars []application.JsonnetVar) map[string]string {
+	values := map[string]string{}
+	for _, v := range vars {
+		values[v.Name] = v.Value
+	}
+	return values
+}
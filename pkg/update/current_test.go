@@ -0,0 +1,118 @@
+package update
+
+import (
+	"testing"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+func TestCurrentImage(t *testing.T) {
+	currentTests := []struct {
+		desc     string
+		src      application.ApplicationSource
+		strategy *appsv1alpha1.UpdateStrategy
+		want     string
+	}{
+		{
+			desc: "no current image",
+			src:  application.ApplicationSource{},
+			want: "",
+		},
+		{
+			desc: "kustomize image",
+			src: application.ApplicationSource{
+				Kustomize: &application.ApplicationSourceKustomize{
+					Images: application.KustomizeImages{"nginx:1.19", testImage2},
+				},
+			},
+			want: testImage2,
+		},
+		{
+			desc: "helm image parameter",
+			src: application.ApplicationSource{
+				Helm: &application.ApplicationSourceHelm{
+					Parameters: []application.HelmParameter{{Name: "image", Value: testImage2}},
+				},
+			},
+			strategy: &appsv1alpha1.UpdateStrategy{
+				Helm: &appsv1alpha1.HelmStrategy{ImageParameter: "image"},
+			},
+			want: testImage2,
+		},
+		{
+			desc: "helm tag parameter",
+			src: application.ApplicationSource{
+				Helm: &application.ApplicationSourceHelm{
+					Parameters: []application.HelmParameter{{Name: "image.tag", Value: "72ab9cc"}},
+				},
+			},
+			strategy: &appsv1alpha1.UpdateStrategy{
+				Helm: &appsv1alpha1.HelmStrategy{RepositoryParameter: "image.repository", TagParameter: "image.tag"},
+			},
+			want: testImage2,
+		},
+		{
+			desc: "helm values",
+			src: application.ApplicationSource{
+				Helm: &application.ApplicationSourceHelm{
+					Values: "services:\n- name: api\n  image:\n    tag: 72ab9cc\n",
+				},
+			},
+			strategy: &appsv1alpha1.UpdateStrategy{
+				Helm: &appsv1alpha1.HelmStrategy{
+					Values: []appsv1alpha1.HelmValue{{Path: "services[0].image.tag", Component: appsv1alpha1.ImageComponentTag}},
+				},
+			},
+			want: testImage2,
+		},
+		{
+			desc: "jsonnet tla",
+			src: application.ApplicationSource{
+				Directory: &application.ApplicationSourceDirectory{
+					Jsonnet: application.ApplicationSourceJsonnet{
+						TLAs: []application.JsonnetVar{{Name: "tag", Value: "72ab9cc"}},
+					},
+				},
+			},
+			strategy: &appsv1alpha1.UpdateStrategy{
+				Jsonnet: &appsv1alpha1.JsonnetStrategy{
+					TLAs: []appsv1alpha1.ImageVariable{{Name: "tag", Component: appsv1alpha1.ImageComponentTag}},
+				},
+			},
+			want: testImage2,
+		},
+		{
+			desc: "plugin env",
+			src: application.ApplicationSource{
+				Plugin: &application.ApplicationSourcePlugin{
+					Env: application.Env{{Name: "IMAGE", Value: testImage2}},
+				},
+			},
+			strategy: &appsv1alpha1.UpdateStrategy{
+				Plugin: &appsv1alpha1.PluginStrategy{
+					Env: []appsv1alpha1.ImageVariable{{Name: "IMAGE"}},
+				},
+			},
+			want: testImage2,
+		},
+		{
+			desc: "target revision",
+			src: application.ApplicationSource{
+				RepoURL:        "docker.io/bigkevmcd",
+				Chart:          "go-demo",
+				TargetRevision: "72ab9cc",
+			},
+			strategy: &appsv1alpha1.UpdateStrategy{
+				TargetRevision: &appsv1alpha1.TargetRevisionStrategy{},
+			},
+			want: testImage2,
+		},
+	}
+
+	for _, tt := range currentTests {
+		if got := CurrentImage(&tt.src, tt.strategy, testImage1); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.desc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package update
+
+import (
+	"errors"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+// SetHelmParameters sets the Helm parameters, and the fields in the inline
+// Helm values, named in the strategy to the image, or its components.
+//
+// Parameters that are already set are replaced, and parameters that are
+// not set are added.
+func SetHelmParameters(src *application.ApplicationSource, strategy *appsv1alpha1.HelmStrategy, img Image) error {
+	params := map[string]string{}
+	if strategy.ImageParameter != "" {
+		params[strategy.ImageParameter] = img.String()
+	}
+	if strategy.RepositoryParameter != "" {
+		params[strategy.RepositoryParameter] = img.Name
+	}
+	if strategy.TagParameter != "" {
+		params[strategy.TagParameter] = img.Version()
+	}
+	if len(params) == 0 && len(strategy.Values) == 0 {
+		return errors.New("the Helm update strategy has no parameters or values")
+	}
+
+	if src.Helm == nil {
+		src.Helm = &application.ApplicationSourceHelm{}
+	}
+	// This is done in the order of the fields in the strategy, so that
+	// new parameters are added consistently.
+	for _, name := range []string{strategy.ImageParameter, strategy.RepositoryParameter, strategy.TagParameter} {
+		if name == "" {
+			continue
+		}
+		src.Helm.AddParameter(application.HelmParameter{Name: name, Value: params[name], ForceString: true})
+	}
+	if len(strategy.Values) > 0 {
+		return SetHelmValues(src.Helm, strategy.Values, img)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package update
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+)
+
+// Image is a container image reference split into its components.
+type Image struct {
+	// Name is the image name, including the registry host if there is one.
+	Name string
+	// Tag is the image tag, it is empty if the reference has no tag.
+	Tag string
+	// Digest is the image digest, it is empty if the reference has no
+	// digest.
+	Digest string
+}
+
+// ParseImage splits an image reference e.g.
+// "docker.io/bigkevmcd/go-demo:af93dae" into its components.
+func ParseImage(s string) Image {
+	var img Image
+	if i := strings.Index(s, "@"); i >= 0 {
+		img.Digest = s[i+1:]
+		s = s[:i]
+	}
+	// A colon after the last slash separates the tag, a colon before it is a
+	// registry port.
+	if i := strings.LastIndex(s, ":"); i >= 0 && i > strings.LastIndex(s, "/") {
+		img.Tag = s[i+1:]
+		s = s[:i]
+	}
+	img.Name = s
+	return img
+}
+
+// String returns the image reference.
+func (i Image) String() string {
+	s := i.Name
+	if i.Tag != "" {
+		s += ":" + i.Tag
+	}
+	if i.Digest != "" {
+		s += "@" + i.Digest
+	}
+	return s
+}
+
+// Version returns the tag of the image, or the digest if the image has no tag.
+func (i Image) Version() string {
+	if i.Tag != "" {
+		return i.Tag
+	}
+	return i.Digest
+}
+
+// Component returns the named component of the image.
+//
+// It is an error if the image does not have the component.
+func (i Image) Component(c appsv1alpha1.ImageComponent) (string, error) {
+	var v string
+	switch c {
+	case "", appsv1alpha1.ImageComponentImage:
+		v = i.String()
+	case appsv1alpha1.ImageComponentName:
+		v = i.Name
+	case appsv1alpha1.ImageComponentTag:
+		v = i.Tag
+	case appsv1alpha1.ImageComponentDigest:
+		v = i.Digest
+	default:
+		return "", fmt.Errorf("unknown image component %q", c)
+	}
+	if v == "" {
+		return "", fmt.Errorf("image %q has no %s", i, strings.ToLower(string(c)))
+	}
+	return v, nil
+}
@@ -0,0 +1,32 @@
+package update
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseImage(t *testing.T) {
+	parseTests := []struct {
+		image string
+		want  Image
+	}{
+		{"nginx", Image{Name: "nginx"}},
+		{"nginx:1.19", Image{Name: "nginx", Tag: "1.19"}},
+		{testImage1, Image{Name: "docker.io/bigkevmcd/go-demo", Tag: "af93dae"}},
+		{"localhost:5000/go-demo", Image{Name: "localhost:5000/go-demo"}},
+		{"localhost:5000/go-demo:v1.0.0", Image{Name: "localhost:5000/go-demo", Tag: "v1.0.0"}},
+		{"go-demo@sha256:abcd", Image{Name: "go-demo", Digest: "sha256:abcd"}},
+		{"localhost:5000/go-demo:v1.0.0@sha256:abcd", Image{Name: "localhost:5000/go-demo", Tag: "v1.0.0", Digest: "sha256:abcd"}},
+	}
+
+	for _, tt := range parseTests {
+		img := ParseImage(tt.image)
+		if diff := cmp.Diff(tt.want, img); diff != "" {
+			t.Errorf("ParseImage(%q) failed:\n%s", tt.image, diff)
+		}
+		if s := img.String(); s != tt.image {
+			t.Errorf("String() got %q, want %q", s, tt.image)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package update
+
+import (
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+// SetInfo adds the entries to the info, replacing any entries with the same
+// name.
+func SetInfo(info []application.Info, entries ...application.Info) []application.Info {
+	for _, e := range entries {
+		found := false
+		for i := range info {
+			if info[i].Name == e.Name {
+				info[i] = e
+				found = true
+				break
+			}
+		}
+		if !found {
+			info = append(info, e)
+		}
+	}
+	return info
+}
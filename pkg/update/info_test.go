@@ -0,0 +1,52 @@
+package update
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+func TestSetInfo(t *testing.T) {
+	infoTests := []struct {
+		desc    string
+		info    []application.Info
+		entries []application.Info
+		want    []application.Info
+	}{
+		{
+			desc:    "no existing entries",
+			entries: []application.Info{{Name: "updated by", Value: "v2"}},
+			want:    []application.Info{{Name: "updated by", Value: "v2"}},
+		},
+		{
+			desc:    "entry added after other entries",
+			info:    []application.Info{{Name: "owner", Value: "team-a"}},
+			entries: []application.Info{{Name: "updated by", Value: "v2"}},
+			want:    []application.Info{{Name: "owner", Value: "team-a"}, {Name: "updated by", Value: "v2"}},
+		},
+		{
+			desc:    "entry updated in place",
+			info:    []application.Info{{Name: "updated by", Value: "v1"}, {Name: "owner", Value: "team-a"}},
+			entries: []application.Info{{Name: "updated by", Value: "v2"}},
+			want:    []application.Info{{Name: "updated by", Value: "v2"}, {Name: "owner", Value: "team-a"}},
+		},
+		{
+			desc:    "entries updated and added",
+			info:    []application.Info{{Name: "owner", Value: "team-a"}, {Name: "updated by", Value: "v1"}},
+			entries: []application.Info{{Name: "updated by", Value: "v2"}, {Name: "details", Value: "https://example.com"}},
+			want: []application.Info{
+				{Name: "owner", Value: "team-a"},
+				{Name: "updated by", Value: "v2"},
+				{Name: "details", Value: "https://example.com"},
+			},
+		},
+	}
+
+	for _, tt := range infoTests {
+		if diff := cmp.Diff(tt.want, SetInfo(tt.info, tt.entries...)); diff != "" {
+			t.Errorf("%s failed comparison:\n%s", tt.desc, diff)
+		}
+	}
+}
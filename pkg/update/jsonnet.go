@@ -0,0 +1,52 @@
+package update
+
+import (
+	"errors"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+// SetJsonnetVars sets the Jsonnet external variables and top-level arguments
+// named in the strategy to the image, or its components.
+//
+// Variables that are already set are replaced, and variables that are not set
+// are added.
+func SetJsonnetVars(src *application.ApplicationSource, strategy *appsv1alpha1.JsonnetStrategy, img Image) error {
+	if len(strategy.ExtVars) == 0 && len(strategy.TLAs) == 0 {
+		return errors.New("the Jsonnet update strategy has no extVars or tlas")
+	}
+	if src.Directory == nil {
+		src.Directory = &application.ApplicationSourceDirectory{}
+	}
+	jsonnet := &src.Directory.Jsonnet
+	var err error
+	if jsonnet.ExtVars, err = setJsonnetVars(jsonnet.ExtVars, strategy.ExtVars, img); err != nil {
+		return err
+	}
+	if jsonnet.TLAs, err = setJsonnetVars(jsonnet.TLAs, strategy.TLAs, img); err != nil {
+		return err
+	}
+	return nil
+}
+
+func setJsonnetVars(existing []application.JsonnetVar, vars []appsv1alpha1.ImageVariable, img Image) ([]application.JsonnetVar, error) {
+	for _, v := range vars {
+		value, err := img.Component(v.Component)
+		if err != nil {
+			return nil, err
+		}
+		existing = setJsonnetVar(existing, application.JsonnetVar{Name: v.Name, Value: value})
+	}
+	return existing, nil
+}
+
+func setJsonnetVar(vars []application.JsonnetVar, v application.JsonnetVar) []application.JsonnetVar {
+	for i := range vars {
+		if vars[i].Name == v.Name {
+			vars[i] = v
+			return vars
+		}
+	}
+	return append(vars, v)
+}
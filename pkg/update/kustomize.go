@@ -0,0 +1,135 @@
+package update
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+// templateData is the data available to commonLabels and commonAnnotations
+// templates.
+type templateData struct {
+	Image  string
+	Name   string
+	Tag    string
+	Digest string
+}
+
+// TemplateError is returned when the templated labels or annotations can't be
+// rendered with the image.
+type TemplateError struct {
+	Err error
+}
+
+func (e *TemplateError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// RenderTemplates executes the Go templates in the values of the map, with the
+// components of the image e.g. "{{ .Tag }}".
+func RenderTemplates(templates map[string]string, img Image) (map[string]string, error) {
+	data := templateData{Image: img.String(), Name: img.Name, Tag: img.Tag, Digest: img.Digest}
+	rendered := map[string]string{}
+	for _, k := range sortedKeys(templates) {
+		tmpl, err := template.New(k).Option("missingkey=error").Parse(templates[k])
+		if err != nil {
+			return nil, &TemplateError{Err: fmt.Errorf("failed to parse the template for %q: %w", k, err)}
+		}
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, &TemplateError{Err: fmt.Errorf("failed to execute the template for %q: %w", k, err)}
+		}
+		rendered[k] = b.String()
+	}
+	return rendered, nil
+}
+
+// SetCommonLabels renders the label templates with the image, and adds them to
+// the source's Kustomize commonLabels.
+func SetCommonLabels(src *application.ApplicationSource, templates map[string]string, img Image) error {
+	labels, err := RenderTemplates(templates, img)
+	if err != nil {
+		return err
+	}
+	for _, k := range sortedKeys(labels) {
+		if errs := validation.IsValidLabelValue(labels[k]); len(errs) > 0 {
+			return &TemplateError{Err: fmt.Errorf("invalid value %q for label %q: %s", labels[k], k, strings.Join(errs, "; "))}
+		}
+	}
+	if src.Kustomize == nil {
+		src.Kustomize = &application.ApplicationSourceKustomize{}
+	}
+	if src.Kustomize.CommonLabels == nil {
+		src.Kustomize.CommonLabels = map[string]string{}
+	}
+	for k, v := range labels {
+		src.Kustomize.CommonLabels[k] = v
+	}
+	return nil
+}
+
+// RemoveCommonLabels removes the labels from the source's Kustomize
+// commonLabels.
+func RemoveCommonLabels(src *application.ApplicationSource, keys []string) {
+	if src.Kustomize == nil {
+		return
+	}
+	for _, k := range keys {
+		delete(src.Kustomize.CommonLabels, k)
+	}
+	if len(src.Kustomize.CommonLabels) == 0 {
+		src.Kustomize.CommonLabels = nil
+	}
+}
+
+// SetCommonAnnotations renders the annotation templates with the image, and
+// adds them to the source's Kustomize commonAnnotations.
+func SetCommonAnnotations(src *application.ApplicationSource, templates map[string]string, img Image) error {
+	annotations, err := RenderTemplates(templates, img)
+	if err != nil {
+		return err
+	}
+	if src.Kustomize == nil {
+		src.Kustomize = &application.ApplicationSourceKustomize{}
+	}
+	if src.Kustomize.CommonAnnotations == nil {
+		src.Kustomize.CommonAnnotations = map[string]string{}
+	}
+	for k, v := range annotations {
+		src.Kustomize.CommonAnnotations[k] = v
+	}
+	return nil
+}
+
+// RemoveCommonAnnotations removes the annotations from the source's Kustomize
+// commonAnnotations.
+func RemoveCommonAnnotations(src *application.ApplicationSource, keys []string) {
+	if src.Kustomize == nil {
+		return
+	}
+	for _, k := range keys {
+		delete(src.Kustomize.CommonAnnotations, k)
+	}
+	if len(src.Kustomize.CommonAnnotations) == 0 {
+		src.Kustomize.CommonAnnotations = nil
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
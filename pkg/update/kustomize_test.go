@@ -0,0 +1,150 @@
+package update
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	rendered, err := RenderTemplates(map[string]string{
+		"app.kubernetes.io/version": "{{ .Tag }}",
+		"example.com/image":         "{{ .Image }}",
+		"example.com/name":          "{{ .Name }}",
+		"example.com/static":        "static-value",
+	}, ParseImage(testImage1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"app.kubernetes.io/version": "af93dae",
+		"example.com/image":         testImage1,
+		"example.com/name":          "docker.io/bigkevmcd/go-demo",
+		"example.com/static":        "static-value",
+	}
+	if diff := cmp.Diff(want, rendered); diff != "" {
+		t.Fatalf("failed to render templates:\n%s", diff)
+	}
+}
+
+func TestRenderTemplatesErrors(t *testing.T) {
+	errTests := []struct {
+		desc     string
+		template string
+		wantErr  string
+	}{
+		{"invalid template", "{{ .Tag ", `failed to parse the template for "version"`},
+		{"unknown field", "{{ .Unknown }}", `failed to execute the template for "version"`},
+	}
+
+	for _, tt := range errTests {
+		_, err := RenderTemplates(map[string]string{"version": tt.template}, ParseImage(testImage1))
+		var tmplErr *TemplateError
+		if !errors.As(err, &tmplErr) {
+			t.Errorf("%s: got error %#v, want a TemplateError", tt.desc, err)
+			continue
+		}
+		if msg := err.Error(); !strings.HasPrefix(msg, tt.wantErr) {
+			t.Errorf("%s: got error %q, want %q", tt.desc, msg, tt.wantErr)
+		}
+	}
+}
+
+func TestSetCommonLabels(t *testing.T) {
+	src := &application.ApplicationSource{
+		Kustomize: &application.ApplicationSourceKustomize{
+			CommonLabels: map[string]string{
+				"app.kubernetes.io/name":    "go-demo",
+				"app.kubernetes.io/version": "72ab9cc",
+			},
+		},
+	}
+
+	err := SetCommonLabels(src, map[string]string{"app.kubernetes.io/version": "{{ .Tag }}"}, ParseImage(testImage1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"app.kubernetes.io/name":    "go-demo",
+		"app.kubernetes.io/version": "af93dae",
+	}
+	if diff := cmp.Diff(want, src.Kustomize.CommonLabels); diff != "" {
+		t.Fatalf("failed to set labels:\n%s", diff)
+	}
+}
+
+func TestSetCommonLabelsWithInvalidValue(t *testing.T) {
+	src := &application.ApplicationSource{}
+
+	err := SetCommonLabels(src, map[string]string{"example.com/image": "{{ .Image }}"}, ParseImage(testImage1))
+
+	var tmplErr *TemplateError
+	if !errors.As(err, &tmplErr) {
+		t.Fatalf("got error %#v, want a TemplateError", err)
+	}
+	if src.Kustomize != nil {
+		t.Fatalf("source was modified: %#v", src.Kustomize)
+	}
+}
+
+func TestRemoveCommonLabels(t *testing.T) {
+	src := &application.ApplicationSource{
+		Kustomize: &application.ApplicationSourceKustomize{
+			CommonLabels: map[string]string{
+				"app.kubernetes.io/name":    "go-demo",
+				"app.kubernetes.io/version": "72ab9cc",
+			},
+		},
+	}
+
+	RemoveCommonLabels(src, []string{"app.kubernetes.io/version"})
+	if diff := cmp.Diff(map[string]string{"app.kubernetes.io/name": "go-demo"}, src.Kustomize.CommonLabels); diff != "" {
+		t.Fatalf("failed to remove labels:\n%s", diff)
+	}
+
+	RemoveCommonLabels(src, []string{"app.kubernetes.io/name"})
+	if src.Kustomize.CommonLabels != nil {
+		t.Fatalf("got labels %#v, want nil", src.Kustomize.CommonLabels)
+	}
+}
+
+func TestSetCommonAnnotations(t *testing.T) {
+	src := &application.ApplicationSource{}
+
+	err := SetCommonAnnotations(src, map[string]string{"example.com/image": "{{ .Image }}"}, ParseImage(testImage1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"example.com/image": testImage1}
+	if diff := cmp.Diff(want, src.Kustomize.CommonAnnotations); diff != "" {
+		t.Fatalf("failed to set annotations:\n%s", diff)
+	}
+}
+
+func TestRemoveCommonAnnotations(t *testing.T) {
+	src := &application.ApplicationSource{
+		Kustomize: &application.ApplicationSourceKustomize{
+			CommonAnnotations: map[string]string{
+				"example.com/image": testImage1,
+				"example.com/owner": "team-a",
+			},
+		},
+	}
+
+	RemoveCommonAnnotations(src, []string{"example.com/image"})
+	if diff := cmp.Diff(map[string]string{"example.com/owner": "team-a"}, src.Kustomize.CommonAnnotations); diff != "" {
+		t.Fatalf("failed to remove annotations:\n%s", diff)
+	}
+
+	RemoveCommonAnnotations(src, []string{"example.com/owner"})
+	if src.Kustomize.CommonAnnotations != nil {
+		t.Fatalf("got annotations %#v, want nil", src.Kustomize.CommonAnnotations)
+	}
+}
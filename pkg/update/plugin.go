@@ -0,0 +1,40 @@
+package update
+
+import (
+	"errors"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+// SetPluginEnv sets the config management plugin environment variables named
+// in the strategy to the image, or its components.
+//
+// Variables that are already set are replaced, and variables that are not set
+// are added.
+func SetPluginEnv(src *application.ApplicationSource, strategy *appsv1alpha1.PluginStrategy, img Image) error {
+	if len(strategy.Env) == 0 {
+		return errors.New("the Plugin update strategy has no env")
+	}
+	if src.Plugin == nil {
+		src.Plugin = &application.ApplicationSourcePlugin{}
+	}
+	for _, v := range strategy.Env {
+		value, err := img.Component(v.Component)
+		if err != nil {
+			return err
+		}
+		src.Plugin.Env = setEnvEntry(src.Plugin.Env, &application.EnvEntry{Name: v.Name, Value: value})
+	}
+	return nil
+}
+
+func setEnvEntry(env application.Env, e *application.EnvEntry) application.Env {
+	for i := range env {
+		if env[i] != nil && env[i].Name == e.Name {
+			env[i] = e
+			return env
+		}
+	}
+	return append(env, e)
+}
@@ -0,0 +1,24 @@
+package update
+
+import (
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+// SetTargetRevision sets the source's targetRevision to the image tag, or the
+// component named in the strategy.
+func SetTargetRevision(src *application.ApplicationSource, strategy *appsv1alpha1.TargetRevisionStrategy, img Image) error {
+	revision, err := img.Component(revisionComponent(strategy))
+	if err != nil {
+		return err
+	}
+	src.TargetRevision = revision
+	return nil
+}
+
+func revisionComponent(strategy *appsv1alpha1.TargetRevisionStrategy) appsv1alpha1.ImageComponent {
+	if strategy.Component == "" {
+		return appsv1alpha1.ImageComponentTag
+	}
+	return strategy.Component
+}
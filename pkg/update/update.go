@@ -1,18 +1,78 @@
 package update
 
 import (
-	argov1alpha1 "github.com/argoproj/argo-cd/pkg/apis/application/v1alpha1"
+	"errors"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
 )
 
-func OverrideImage(a *argov1alpha1.Application, newImage argov1alpha1.KustomizeImage) error {
+// Apply writes the image to the source, according to the strategy.
+//
+// If the strategy is nil, the image is added to the Kustomize images.
+func Apply(src *application.ApplicationSource, strategy *appsv1alpha1.UpdateStrategy, image string) error {
+	if strategy == nil {
+		strategy = &appsv1alpha1.UpdateStrategy{Kustomize: &appsv1alpha1.KustomizeStrategy{}}
+	}
+	if strategy.Kustomize == nil && strategy.Helm == nil && strategy.Jsonnet == nil && strategy.Plugin == nil && strategy.TargetRevision == nil {
+		return errors.New("the update strategy has no Kustomize, Helm, Jsonnet, Plugin or TargetRevision configuration")
+	}
+	img := ParseImage(image)
+	if strategy.Kustomize != nil {
+		OverrideSourceImage(src, application.KustomizeImage(image))
+		if len(strategy.Kustomize.CommonLabels) > 0 {
+			if err := SetCommonLabels(src, strategy.Kustomize.CommonLabels, img); err != nil {
+				return err
+			}
+		}
+		if len(strategy.Kustomize.CommonAnnotations) > 0 {
+			if err := SetCommonAnnotations(src, strategy.Kustomize.CommonAnnotations, img); err != nil {
+				return err
+			}
+		}
+	}
+	if strategy.Helm != nil {
+		if err := SetHelmParameters(src, strategy.Helm, img); err != nil {
+			return err
+		}
+	}
+	if strategy.Jsonnet != nil {
+		if err := SetJsonnetVars(src, strategy.Jsonnet, img); err != nil {
+			return err
+		}
+	}
+	if strategy.Plugin != nil {
+		if err := SetPluginEnv(src, strategy.Plugin, img); err != nil {
+			return err
+		}
+	}
+	if strategy.TargetRevision != nil {
+		if err := SetTargetRevision(src, strategy.TargetRevision, img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func OverrideImage(a *application.Application, newImage application.KustomizeImage) error {
 	images := a.Spec.Source.Kustomize.Images
 	images = removeImage(images, newImage)
 	a.Spec.Source.Kustomize.Images = append(images, newImage)
 	return nil
 }
 
-func removeImage(imgs []argov1alpha1.KustomizeImage, img argov1alpha1.KustomizeImage) []argov1alpha1.KustomizeImage {
-	updated := []argov1alpha1.KustomizeImage{}
+// OverrideSourceImage adds the image to the source's Kustomize images,
+// replacing any image with the same name.
+func OverrideSourceImage(src *application.ApplicationSource, newImage application.KustomizeImage) {
+	if src.Kustomize == nil {
+		src.Kustomize = &application.ApplicationSourceKustomize{}
+	}
+	images := removeImage(src.Kustomize.Images, newImage)
+	src.Kustomize.Images = append(images, newImage)
+}
+
+func removeImage(imgs []application.KustomizeImage, img application.KustomizeImage) []application.KustomizeImage {
+	updated := []application.KustomizeImage{}
 	for _, v := range imgs {
 		if !v.Match(img) {
 			updated = append(updated, v)
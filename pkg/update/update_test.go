@@ -3,8 +3,10 @@ package update
 import (
 	"testing"
 
-	argov1alpha1 "github.com/argoproj/argo-cd/pkg/apis/application/v1alpha1"
 	"github.com/google/go-cmp/cmp"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
 )
 
 const (
@@ -15,33 +17,33 @@ const (
 func TestOverrideImages(t *testing.T) {
 	updateTests := []struct {
 		desc    string
-		initial argov1alpha1.KustomizeImages
-		want    argov1alpha1.KustomizeImages
+		initial application.KustomizeImages
+		want    application.KustomizeImages
 	}{
 		{
 			desc:    "empty set",
-			initial: []argov1alpha1.KustomizeImage{},
-			want:    []argov1alpha1.KustomizeImage{testImage1},
+			initial: []application.KustomizeImage{},
+			want:    []application.KustomizeImage{testImage1},
 		},
 		{
 			desc:    "existing image - different tag",
-			initial: []argov1alpha1.KustomizeImage{testImage2},
-			want:    []argov1alpha1.KustomizeImage{testImage1},
+			initial: []application.KustomizeImage{testImage2},
+			want:    []application.KustomizeImage{testImage1},
 		},
 		{
 			desc:    "existing image - same tag",
-			initial: []argov1alpha1.KustomizeImage{testImage1},
-			want:    []argov1alpha1.KustomizeImage{testImage1},
+			initial: []application.KustomizeImage{testImage1},
+			want:    []application.KustomizeImage{testImage1},
 		},
 	}
 
 	for _, tt := range updateTests {
-		kust := &argov1alpha1.ApplicationSourceKustomize{
+		kust := &application.ApplicationSourceKustomize{
 			Images: tt.initial,
 		}
-		app := argov1alpha1.Application{
-			Spec: argov1alpha1.ApplicationSpec{
-				Source: argov1alpha1.ApplicationSource{
+		app := application.Application{
+			Spec: application.ApplicationSpec{
+				Source: application.ApplicationSource{
 					Kustomize: kust,
 				},
 			},
@@ -54,3 +56,228 @@ func TestOverrideImages(t *testing.T) {
 		}
 	}
 }
+
+func TestApply(t *testing.T) {
+	applyTests := []struct {
+		desc     string
+		initial  application.ApplicationSource
+		strategy *appsv1alpha1.UpdateStrategy
+		want     application.ApplicationSource
+	}{
+		{
+			desc:     "no strategy",
+			initial:  application.ApplicationSource{},
+			strategy: nil,
+			want: application.ApplicationSource{
+				Kustomize: &application.ApplicationSourceKustomize{
+					Images: application.KustomizeImages{testImage1},
+				},
+			},
+		},
+		{
+			desc: "kustomize strategy",
+			initial: application.ApplicationSource{
+				Kustomize: &application.ApplicationSourceKustomize{
+					Images: application.KustomizeImages{testImage2},
+				},
+			},
+			strategy: &appsv1alpha1.UpdateStrategy{
+				Kustomize: &appsv1alpha1.KustomizeStrategy{},
+			},
+			want: application.ApplicationSource{
+				Kustomize: &application.ApplicationSourceKustomize{
+					Images: application.KustomizeImages{testImage1},
+				},
+			},
+		},
+		{
+			desc:    "helm strategy with new parameters",
+			initial: application.ApplicationSource{},
+			strategy: &appsv1alpha1.UpdateStrategy{
+				Helm: &appsv1alpha1.HelmStrategy{
+					ImageParameter:      "image.full",
+					RepositoryParameter: "image.repository",
+					TagParameter:        "image.tag",
+				},
+			},
+			want: application.ApplicationSource{
+				Helm: &application.ApplicationSourceHelm{
+					Parameters: []application.HelmParameter{
+						{Name: "image.full", Value: testImage1, ForceString: true},
+						{Name: "image.repository", Value: "docker.io/bigkevmcd/go-demo", ForceString: true},
+						{Name: "image.tag", Value: "af93dae", ForceString: true},
+					},
+				},
+			},
+		},
+		{
+			desc: "helm strategy with existing parameters",
+			initial: application.ApplicationSource{
+				Helm: &application.ApplicationSourceHelm{
+					Parameters: []application.HelmParameter{
+						{Name: "replicas", Value: "2"},
+						{Name: "image.tag", Value: "72ab9cc"},
+					},
+				},
+			},
+			strategy: &appsv1alpha1.UpdateStrategy{
+				Helm: &appsv1alpha1.HelmStrategy{
+					TagParameter: "image.tag",
+				},
+			},
+			want: application.ApplicationSource{
+				Helm: &application.ApplicationSourceHelm{
+					Parameters: []application.HelmParameter{
+						{Name: "replicas", Value: "2"},
+						{Name: "image.tag", Value: "af93dae", ForceString: true},
+					},
+				},
+			},
+		},
+		{
+			desc: "jsonnet strategy",
+			initial: application.ApplicationSource{
+				Directory: &application.ApplicationSourceDirectory{
+					Jsonnet: application.ApplicationSourceJsonnet{
+						ExtVars: []application.JsonnetVar{
+							{Name: "image", Value: testImage2},
+							{Name: "replicas", Value: "2", Code: true},
+						},
+					},
+				},
+			},
+			strategy: &appsv1alpha1.UpdateStrategy{
+				Jsonnet: &appsv1alpha1.JsonnetStrategy{
+					ExtVars: []appsv1alpha1.ImageVariable{
+						{Name: "image"},
+					},
+					TLAs: []appsv1alpha1.ImageVariable{
+						{Name: "tag", Component: appsv1alpha1.ImageComponentTag},
+					},
+				},
+			},
+			want: application.ApplicationSource{
+				Directory: &application.ApplicationSourceDirectory{
+					Jsonnet: application.ApplicationSourceJsonnet{
+						ExtVars: []application.JsonnetVar{
+							{Name: "image", Value: testImage1},
+							{Name: "replicas", Value: "2", Code: true},
+						},
+						TLAs: []application.JsonnetVar{
+							{Name: "tag", Value: "af93dae"},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "plugin strategy",
+			initial: application.ApplicationSource{
+				Plugin: &application.ApplicationSourcePlugin{
+					Name: "envsubst",
+					Env: application.Env{
+						{Name: "IMAGE_NAME", Value: "docker.io/bigkevmcd/old-demo"},
+					},
+				},
+			},
+			strategy: &appsv1alpha1.UpdateStrategy{
+				Plugin: &appsv1alpha1.PluginStrategy{
+					Env: []appsv1alpha1.ImageVariable{
+						{Name: "IMAGE_NAME", Component: appsv1alpha1.ImageComponentName},
+						{Name: "IMAGE_TAG", Component: appsv1alpha1.ImageComponentTag},
+					},
+				},
+			},
+			want: application.ApplicationSource{
+				Plugin: &application.ApplicationSourcePlugin{
+					Name: "envsubst",
+					Env: application.Env{
+						{Name: "IMAGE_NAME", Value: "docker.io/bigkevmcd/go-demo"},
+						{Name: "IMAGE_TAG", Value: "af93dae"},
+					},
+				},
+			},
+		},
+		{
+			desc: "target revision strategy",
+			initial: application.ApplicationSource{
+				RepoURL:        "docker.io/bigkevmcd",
+				Chart:          "go-demo",
+				TargetRevision: "72ab9cc",
+			},
+			strategy: &appsv1alpha1.UpdateStrategy{
+				TargetRevision: &appsv1alpha1.TargetRevisionStrategy{},
+			},
+			want: application.ApplicationSource{
+				RepoURL:        "docker.io/bigkevmcd",
+				Chart:          "go-demo",
+				TargetRevision: "af93dae",
+			},
+		},
+	}
+
+	for _, tt := range applyTests {
+		src := tt.initial
+		if err := Apply(&src, tt.strategy, testImage1); err != nil {
+			t.Errorf("%s failed: %s", tt.desc, err)
+			continue
+		}
+
+		if diff := cmp.Diff(tt.want, src); diff != "" {
+			t.Errorf("%s failed comparison:\n%s", tt.desc, diff)
+		}
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	errTests := []struct {
+		desc     string
+		strategy *appsv1alpha1.UpdateStrategy
+		wantErr  string
+	}{
+		{
+			desc:     "empty strategy",
+			strategy: &appsv1alpha1.UpdateStrategy{},
+			wantErr:  "the update strategy has no Kustomize, Helm, Jsonnet, Plugin or TargetRevision configuration",
+		},
+		{
+			desc: "helm strategy without parameters",
+			strategy: &appsv1alpha1.UpdateStrategy{
+				Helm: &appsv1alpha1.HelmStrategy{},
+			},
+			wantErr: "the Helm update strategy has no parameters or values",
+		},
+		{
+			desc: "jsonnet strategy without variables",
+			strategy: &appsv1alpha1.UpdateStrategy{
+				Jsonnet: &appsv1alpha1.JsonnetStrategy{},
+			},
+			wantErr: "the Jsonnet update strategy has no extVars or tlas",
+		},
+		{
+			desc: "plugin strategy without variables",
+			strategy: &appsv1alpha1.UpdateStrategy{
+				Plugin: &appsv1alpha1.PluginStrategy{},
+			},
+			wantErr: "the Plugin update strategy has no env",
+		},
+		{
+			desc: "missing image component",
+			strategy: &appsv1alpha1.UpdateStrategy{
+				Plugin: &appsv1alpha1.PluginStrategy{
+					Env: []appsv1alpha1.ImageVariable{
+						{Name: "IMAGE_DIGEST", Component: appsv1alpha1.ImageComponentDigest},
+					},
+				},
+			},
+			wantErr: `image "docker.io/bigkevmcd/go-demo:af93dae" has no digest`,
+		},
+	}
+
+	for _, tt := range errTests {
+		err := Apply(&application.ApplicationSource{}, tt.strategy, testImage1)
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("%s: got error %v, want %q", tt.desc, err, tt.wantErr)
+		}
+	}
+}
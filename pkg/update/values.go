@@ -0,0 +1,237 @@
+package update
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+// SetHelmValues sets the fields in the inline Helm values to the image, or its
+// components.
+//
+// The values are edited as YAML nodes, so that their comments and order are
+// kept, and they are written with the indentation that they were read with.
+func SetHelmValues(helm *application.ApplicationSourceHelm, values []appsv1alpha1.HelmValue, img Image) error {
+	doc, err := parseValues(helm.Values)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		value, err := img.Component(v.Component)
+		if err != nil {
+			return err
+		}
+		path, err := parseValuesPath(v.Path)
+		if err != nil {
+			return err
+		}
+		if err := setValuesField(doc.Content[0], path, v.Path, value); err != nil {
+			return err
+		}
+	}
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(valuesIndent(doc.Content[0]))
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode the Helm values: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to encode the Helm values: %w", err)
+	}
+	helm.Values = b.String()
+	return nil
+}
+
+// helmValues returns the values of the fields at the paths in the inline Helm
+// values, fields that are missing, or are not scalars, are not returned.
+func helmValues(values string, paths []appsv1alpha1.HelmValue) map[string]string {
+	found := map[string]string{}
+	doc, err := parseValues(values)
+	if err != nil {
+		return found
+	}
+	for _, v := range paths {
+		path, err := parseValuesPath(v.Path)
+		if err != nil {
+			continue
+		}
+		if node := lookupValuesField(doc.Content[0], path); node != nil && node.Kind == yaml.ScalarNode {
+			found[v.Path] = node.Value
+		}
+	}
+	return found
+}
+
+// parseValues parses the values into a document with a mapping, an empty
+// mapping is returned if there are no values.
+func parseValues(values string) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(values), &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse the Helm values: %w", err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("the Helm values are not a mapping")
+	}
+	return &doc, nil
+}
+
+// valuesIndent returns the indentation of the first nested block in the values,
+// or 2 if there are none.
+func valuesIndent(node *yaml.Node) int {
+	if indent, ok := blockIndent(node); ok {
+		return indent
+	}
+	return 2
+}
+
+// blockIndent returns how far the first block nested under a key in the node
+// is indented from the key.
+//
+// The column of a list entry is after its "- ", and lists that are not
+// indented from their key are skipped, as they are written indented.
+func blockIndent(node *yaml.Node) (int, bool) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if value.Style&yaml.FlowStyle != 0 || len(value.Content) == 0 {
+				continue
+			}
+			indent := 0
+			switch value.Kind {
+			case yaml.MappingNode:
+				indent = value.Content[0].Column - key.Column
+			case yaml.SequenceNode:
+				indent = value.Content[0].Column - len("- ") - key.Column
+			}
+			if indent > 0 {
+				return indent, true
+			}
+		}
+	}
+	for _, child := range node.Content {
+		if indent, ok := blockIndent(child); ok {
+			return indent, true
+		}
+	}
+	return 0, false
+}
+
+// valuesPathElement is a key, or a list index, in a path to a field in the
+// Helm values.
+type valuesPathElement struct {
+	key   string
+	index int
+}
+
+func (e valuesPathElement) isIndex() bool {
+	return e.key == ""
+}
+
+// parseValuesPath parses a path e.g. "services[2].image" into its keys and
+// list indexes.
+func parseValuesPath(path string) ([]valuesPathElement, error) {
+	var elements []valuesPathElement
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		if i := strings.Index(part, "["); i >= 0 {
+			key = part[:i]
+		}
+		if key == "" {
+			return nil, fmt.Errorf("invalid Helm values path %q: empty key", path)
+		}
+		elements = append(elements, valuesPathElement{key: key})
+		for rest := part[len(key):]; rest != ""; {
+			end := strings.Index(rest, "]")
+			if rest[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("invalid Helm values path %q: malformed index in %q", path, part)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid Helm values path %q: invalid index %q", path, rest[1:end])
+			}
+			elements = append(elements, valuesPathElement{index: index})
+			rest = rest[end+1:]
+		}
+	}
+	return elements, nil
+}
+
+// setValuesField sets the scalar at the path to the value, adding missing
+// keys.
+//
+// The value is always written as a string, so that tags like "1.19" are not
+// read as numbers.
+func setValuesField(node *yaml.Node, path []valuesPathElement, name, value string) error {
+	for i, e := range path {
+		if e.isIndex() {
+			if node.Kind != yaml.SequenceNode || e.index >= len(node.Content) {
+				return fmt.Errorf("failed to set Helm value %q: there is no list entry %d", name, e.index)
+			}
+			node = node.Content[e.index]
+			continue
+		}
+		if isNull(node) {
+			*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", HeadComment: node.HeadComment, LineComment: node.LineComment}
+		}
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("failed to set Helm value %q: %q is not a mapping", name, e.key)
+		}
+		child := mappingValue(node, e.key)
+		if child == nil {
+			if i+1 < len(path) && path[i+1].isIndex() {
+				return fmt.Errorf("failed to set Helm value %q: there is no list %q", name, e.key)
+			}
+			child = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: e.key}, child)
+		}
+		node = child
+	}
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("failed to set Helm value %q: the field is not a scalar", name)
+	}
+	node.Tag = "!!str"
+	node.Value = value
+	return nil
+}
+
+// lookupValuesField returns the node at the path, or nil if there is none.
+func lookupValuesField(node *yaml.Node, path []valuesPathElement) *yaml.Node {
+	for _, e := range path {
+		switch {
+		case e.isIndex():
+			if node.Kind != yaml.SequenceNode || e.index >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[e.index]
+		case node.Kind == yaml.MappingNode:
+			if node = mappingValue(node, e.key); node == nil {
+				return nil
+			}
+		default:
+			return nil
+		}
+	}
+	return node
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
@@ -0,0 +1,182 @@
+package update
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	appsv1alpha1 "github.com/bigkevmcd/image-policy-argo-updater/api/v1alpha1"
+	"github.com/bigkevmcd/image-policy-argo-updater/pkg/application"
+)
+
+const testValues = `# The services to deploy.
+services:
+  - name: frontend
+    image: nginx:1.19
+  - name: api
+    image: docker.io/bigkevmcd/go-demo:72ab9cc # the API server
+replicas: 2
+`
+
+func TestSetHelmValues(t *testing.T) {
+	valuesTests := []struct {
+		desc    string
+		initial string
+		image   string
+		values  []appsv1alpha1.HelmValue
+		want    string
+	}{
+		{
+			desc:    "list entry",
+			initial: testValues,
+			image:   testImage1,
+			values:  []appsv1alpha1.HelmValue{{Path: "services[1].image"}},
+			want: `# The services to deploy.
+services:
+  - name: frontend
+    image: nginx:1.19
+  - name: api
+    image: docker.io/bigkevmcd/go-demo:af93dae # the API server
+replicas: 2
+`,
+		},
+		{
+			desc:    "missing keys",
+			initial: testValues,
+			image:   testImage1,
+			values: []appsv1alpha1.HelmValue{
+				{Path: "image.repository", Component: appsv1alpha1.ImageComponentName},
+				{Path: "image.tag", Component: appsv1alpha1.ImageComponentTag},
+			},
+			want: `# The services to deploy.
+services:
+  - name: frontend
+    image: nginx:1.19
+  - name: api
+    image: docker.io/bigkevmcd/go-demo:72ab9cc # the API server
+replicas: 2
+image:
+  repository: docker.io/bigkevmcd/go-demo
+  tag: af93dae
+`,
+		},
+		{
+			desc: "four space indentation",
+			initial: `# The image to deploy.
+image:
+    repository: docker.io/bigkevmcd/go-demo
+    tag: 72ab9cc # the API server
+service:
+    ports:
+        - name: http
+          port: 8080
+`,
+			image:  testImage1,
+			values: []appsv1alpha1.HelmValue{{Path: "image.tag", Component: appsv1alpha1.ImageComponentTag}},
+			want: `# The image to deploy.
+image:
+    repository: docker.io/bigkevmcd/go-demo
+    tag: af93dae # the API server
+service:
+    ports:
+        - name: http
+          port: 8080
+`,
+		},
+		{
+			desc:    "no values",
+			initial: "",
+			image:   testImage1,
+			values:  []appsv1alpha1.HelmValue{{Path: "image.tag", Component: appsv1alpha1.ImageComponentTag}},
+			want:    "image:\n  tag: af93dae\n",
+		},
+		{
+			desc:    "numeric tag",
+			initial: "image:\n  tag: \"1.18\"\n",
+			image:   "nginx:1.19",
+			values:  []appsv1alpha1.HelmValue{{Path: "image.tag", Component: appsv1alpha1.ImageComponentTag}},
+			want:    "image:\n  tag: \"1.19\"\n",
+		},
+	}
+
+	for _, tt := range valuesTests {
+		t.Run(tt.desc, func(t *testing.T) {
+			helm := &application.ApplicationSourceHelm{Values: tt.initial}
+
+			if err := SetHelmValues(helm, tt.values, ParseImage(tt.image)); err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, helm.Values); diff != "" {
+				t.Fatalf("failed to set the values:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSetHelmValuesErrors(t *testing.T) {
+	errTests := []struct {
+		desc    string
+		initial string
+		path    string
+		wantErr string
+	}{
+		{
+			desc:    "list entry out of range",
+			initial: testValues,
+			path:    "services[2].image",
+			wantErr: `failed to set Helm value "services[2].image": there is no list entry 2`,
+		},
+		{
+			desc:    "missing list",
+			initial: testValues,
+			path:    "workers[0].image",
+			wantErr: `failed to set Helm value "workers[0].image": there is no list "workers"`,
+		},
+		{
+			desc:    "not a mapping",
+			initial: testValues,
+			path:    "replicas.image",
+			wantErr: `failed to set Helm value "replicas.image": "image" is not a mapping`,
+		},
+		{
+			desc:    "not a scalar",
+			initial: testValues,
+			path:    "services",
+			wantErr: `failed to set Helm value "services": the field is not a scalar`,
+		},
+		{
+			desc:    "invalid index",
+			initial: testValues,
+			path:    "services[a].image",
+			wantErr: `invalid Helm values path "services[a].image": invalid index "a"`,
+		},
+		{
+			desc:    "empty key",
+			initial: testValues,
+			path:    "services..image",
+			wantErr: `invalid Helm values path "services..image": empty key`,
+		},
+		{
+			desc:    "values are not a mapping",
+			initial: "- image: nginx:1.19\n",
+			path:    "image",
+			wantErr: "the Helm values are not a mapping",
+		},
+	}
+
+	for _, tt := range errTests {
+		t.Run(tt.desc, func(t *testing.T) {
+			helm := &application.ApplicationSourceHelm{Values: tt.initial}
+
+			err := SetHelmValues(helm, []appsv1alpha1.HelmValue{{Path: tt.path}}, ParseImage(testImage1))
+
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+			if helm.Values != tt.initial {
+				t.Fatalf("values were changed to %q", helm.Values)
+			}
+		})
+	}
+}